@@ -0,0 +1,259 @@
+package mexif
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BatchSize is the maximum number of files sent to exiftool in a single -execute
+const BatchSize = 100
+
+// BatchError collects the files that could not be read in a batch read. The files that were
+// read successfully are still returned.
+type BatchError struct {
+	Errors map[string]error
+}
+
+func (e *BatchError) Error() string {
+	paths := make([]string, 0, len(e.Errors))
+	for p := range e.Errors {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if len(paths) > 3 {
+		paths = append(paths[:3], "...")
+	}
+	return fmt.Sprintf("%d files could not be read: %s", len(e.Errors), strings.Join(paths, ", "))
+}
+
+// ReadMany is like ReadManyContext. The timeout set with SetTimeout applies per file, a chunk
+// of n files has n times the timeout to finish before its files are read one by one
+func (tool *MExifTool) ReadMany(paths []string, flags ...string) (map[string]map[string]interface{}, error) {
+	return readMany(context.Background(), tool.readArgs, 1, atomic.LoadInt64(&tool.timeout), paths, flags)
+}
+
+// ReadManyContext reads paths in chunks of BatchSize and returns the unmarshalled output
+// keyed by SourceFile. Files that could not be read are reported in a *BatchError.
+func (tool *MExifTool) ReadManyContext(ctx context.Context, paths []string, flags ...string) (map[string]map[string]interface{}, error) {
+	return readMany(ctx, tool.readArgs, 1, 0, paths, flags)
+}
+
+func (tool *MExifTool) ExifDataMany(paths []string) (map[string]*ExifData, error) {
+	return exifDataMany(context.Background(), tool.readArgs, 1, atomic.LoadInt64(&tool.timeout), paths)
+}
+
+func (tool *MExifTool) ExifDataManyContext(ctx context.Context, paths []string) (map[string]*ExifData, error) {
+	return exifDataMany(ctx, tool.readArgs, 1, 0, paths)
+}
+
+func (tool *MExifTool) ExifCompactMany(paths []string) (map[string]*ExifCompact, error) {
+	return exifCompactMany(tool.ExifDataMany(paths))
+}
+
+func (tool *MExifTool) ExifCompactManyContext(ctx context.Context, paths []string) (map[string]*ExifCompact, error) {
+	return exifCompactMany(tool.ExifDataManyContext(ctx, paths))
+}
+
+// ReadMany is like MExifTool.ReadMany but reads the chunks in parallel on the processes of
+// the pool
+func (pool *MExifToolPool) ReadMany(paths []string, flags ...string) (map[string]map[string]interface{}, error) {
+	return readMany(context.Background(), pool.readArgs, cap(pool.slots), atomic.LoadInt64(&pool.timeout), paths, flags)
+}
+
+func (pool *MExifToolPool) ReadManyContext(ctx context.Context, paths []string, flags ...string) (map[string]map[string]interface{}, error) {
+	return readMany(ctx, pool.readArgs, cap(pool.slots), 0, paths, flags)
+}
+
+func (pool *MExifToolPool) ExifDataMany(paths []string) (map[string]*ExifData, error) {
+	return exifDataMany(context.Background(), pool.readArgs, cap(pool.slots), atomic.LoadInt64(&pool.timeout), paths)
+}
+
+func (pool *MExifToolPool) ExifDataManyContext(ctx context.Context, paths []string) (map[string]*ExifData, error) {
+	return exifDataMany(ctx, pool.readArgs, cap(pool.slots), 0, paths)
+}
+
+func (pool *MExifToolPool) ExifCompactMany(paths []string) (map[string]*ExifCompact, error) {
+	return exifCompactMany(pool.ExifDataMany(paths))
+}
+
+func (pool *MExifToolPool) ExifCompactManyContext(ctx context.Context, paths []string) (map[string]*ExifCompact, error) {
+	return exifCompactMany(pool.ExifDataManyContext(ctx, paths))
+}
+
+type argsReader func(ctx context.Context, mode outputMode, args []string) (*response, error)
+
+type batchEntry struct {
+	root     map[string]interface{}
+	warnings []string
+}
+
+type batch struct {
+	mutex   sync.Mutex
+	entries map[string]*batchEntry
+	errs    map[string]error
+}
+
+func (b *batch) add(path string, entry *batchEntry) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.entries[path] = entry
+}
+
+func (b *batch) fail(path string, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.errs[path] = err
+}
+
+func (b *batch) err() error {
+	if len(b.errs) > 0 {
+		return &BatchError{Errors: b.errs}
+	}
+	return nil
+}
+
+func readMany(ctx context.Context, read argsReader, workers int, timeout int64, paths []string, flags []string) (map[string]map[string]interface{}, error) {
+	b, err := readBatch(ctx, read, workers, timeout, paths, flags)
+	ret := make(map[string]map[string]interface{}, len(b.entries))
+	for p, e := range b.entries {
+		ret[p] = e.root
+	}
+	if err != nil {
+		return ret, err
+	}
+	return ret, b.err()
+}
+
+func exifDataMany(ctx context.Context, read argsReader, workers int, timeout int64, paths []string) (map[string]*ExifData, error) {
+	b, err := readBatch(ctx, read, workers, timeout, paths, nil)
+	ret := make(map[string]*ExifData, len(b.entries))
+	for p, e := range b.entries {
+		if d, err := newExifData(p, e.root, e.warnings); err != nil {
+			b.errs[p] = err
+		} else {
+			ret[p] = d
+		}
+	}
+	if err != nil {
+		return ret, err
+	}
+	return ret, b.err()
+}
+
+func exifCompactMany(data map[string]*ExifData, err error) (map[string]*ExifCompact, error) {
+	ret := make(map[string]*ExifCompact, len(data))
+	for p, d := range data {
+		ret[p] = NewExifCompact(d)
+	}
+	return ret, err
+}
+
+// readBatch reads paths in chunks using up to workers concurrent readers. The returned error
+// is only set if the batch had to be abandoned, per file errors are kept in the batch.
+func readBatch(ctx context.Context, read argsReader, workers int, timeout int64, paths []string, flags []string) (*batch, error) {
+	b := batch{entries: map[string]*batchEntry{}, errs: map[string]error{}}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var fatal error
+	var once sync.Once
+
+	chunks := make(chan []string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				if err := readChunk(ctx, read, timeout, chunk, flags, &b); err != nil {
+					once.Do(func() {
+						fatal = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+send:
+	for start := 0; start < len(paths); start += BatchSize {
+		end := start + BatchSize
+		if end > len(paths) {
+			end = len(paths)
+		}
+		select {
+		case chunks <- paths[start:end]:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(chunks)
+	wg.Wait()
+
+	once.Do(func() {
+		fatal = ctx.Err()
+	})
+	return &b, fatal
+}
+
+// readChunk reads chunk with timeout per file. If the chunk crashes exiftool or times out its
+// files are read one by one
+func readChunk(ctx context.Context, read argsReader, timeout int64, chunk []string, flags []string, b *batch) error {
+	cctx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		cctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Duration(len(chunk)))
+		defer cancel()
+	}
+	resp, err := read(cctx, jsonOutput, jsonArgs(chunk, flags))
+	switch {
+	case err == nil:
+	case len(chunk) > 1 && (errors.Is(err, ErrProcessExited) || cctx.Err() != nil && ctx.Err() == nil):
+		//one of the files crashes or hangs exiftool, read them one by one to find it
+		for _, p := range chunk {
+			if err := readChunk(ctx, read, timeout, []string{p}, flags, b); err != nil {
+				return err
+			}
+		}
+		return nil
+	case errors.Is(err, ErrProcessExited) || cctx.Err() != nil && ctx.Err() == nil:
+		b.fail(chunk[0], err)
+		return nil
+	default:
+		return err
+	}
+
+	if resp.decodeErr != nil {
+		for _, p := range chunk {
+			b.fail(p, resp.decodeErr)
+		}
+		return nil
+	}
+
+	warnings := resp.fileMessages(warningPrefix, chunk)
+	found := map[string]bool{}
+	for _, root := range resp.roots {
+		src, _ := root["SourceFile"].(string)
+		found[src] = true
+		b.add(src, &batchEntry{root: root, warnings: warnings[src]})
+	}
+
+	errs := resp.fileMessages(errorPrefix, chunk)
+	for _, p := range chunk {
+		if found[p] {
+			continue
+		}
+		if msgs := errs[p]; len(msgs) > 0 {
+			b.fail(p, newExifToolError(p, msgs[0]))
+		} else {
+			b.fail(p, fmt.Errorf("no data for %s", p))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package mexif
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testBatchReader returns a reader that takes perFile for each file of a request and never
+// finishes a request that includes a path containing hang
+func testBatchReader(perFile time.Duration, calls *[][]string) argsReader {
+	var mutex sync.Mutex
+	return func(ctx context.Context, mode outputMode, args []string) (*response, error) {
+		var paths []string
+		for _, a := range args {
+			if !strings.HasPrefix(a, "-") {
+				paths = append(paths, a)
+			}
+		}
+		mutex.Lock()
+		*calls = append(*calls, paths)
+		mutex.Unlock()
+		delay := perFile * time.Duration(len(paths))
+		for _, p := range paths {
+			if strings.Contains(p, "hang") {
+				delay = time.Hour
+			}
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		resp := response{}
+		for _, p := range paths {
+			resp.roots = append(resp.roots, map[string]interface{}{"SourceFile": p})
+		}
+		return &resp, nil
+	}
+}
+
+func TestReadManyTimeout(t *testing.T) {
+	//each file is within the timeout but the chunk is not, the chunk deadline scales with its length
+	var calls [][]string
+	paths := []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg"}
+	timeout := int64(100 * time.Millisecond)
+	if ret, err := readMany(context.Background(), testBatchReader(40*time.Millisecond, &calls), 1, timeout, paths, nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	} else if len(ret) != len(paths) || len(calls) != 1 {
+		t.Errorf("expected %d files in a single request got %d in %d", len(paths), len(ret), len(calls))
+	}
+
+	//a chunk that times out is read one by one and only the hanging file fails
+	calls = nil
+	paths = []string{"a.jpg", "hang.jpg", "b.jpg"}
+	ret, err := readMany(context.Background(), testBatchReader(time.Millisecond, &calls), 1, int64(50*time.Millisecond), paths, nil)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Errors) != 1 || !errors.Is(batchErr.Errors["hang.jpg"], context.DeadlineExceeded) {
+		t.Fatalf("expected hang.jpg to time out got %v", err)
+	}
+	if len(ret) != 2 || ret["a.jpg"] == nil || ret["b.jpg"] == nil {
+		t.Errorf("expected a.jpg and b.jpg to be read got %v", ret)
+	}
+	if len(calls) != 1+len(paths) {
+		t.Errorf("expected the chunk and then each file to be read got %v", calls)
+	}
+}
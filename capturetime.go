@@ -0,0 +1,74 @@
+package mexif
+
+import (
+	"github.com/msvens/mexif/json"
+)
+
+// CaptureTime is when a photo or video was taken, with the precision of the value and whether
+// its time zone was known
+type CaptureTime struct {
+	json.Timestamp
+	//Tag is the tag the time was read from, e.g. DateTimeOriginal
+	Tag string
+}
+
+// dateSource is a date tag of the Time group with the tags that complete it. time is the time
+// of a date only tag, e.g. the IPTC TimeCreated
+type dateSource struct {
+	tag    string
+	subSec string
+	offset string
+	time   string
+}
+
+var originalDates = []dateSource{
+	{tag: "SubSecDateTimeOriginal"},
+	{tag: "DateTimeOriginal", subSec: "SubSecTimeOriginal", offset: "OffsetTimeOriginal"},
+}
+
+var createDates = []dateSource{
+	{tag: "SubSecCreateDate"},
+	{tag: "CreateDate", subSec: "SubSecTimeDigitized", offset: "OffsetTimeDigitized"},
+}
+
+var modifyDates = []dateSource{
+	{tag: "SubSecModifyDate"},
+	{tag: "ModifyDate", subSec: "SubSecTime", offset: "OffsetTime"},
+}
+
+// iptcDates are the IPTC-IIM and XMP photoshop creation dates
+var iptcDates = []dateSource{
+	{tag: "DateTimeCreated"},
+	{tag: "DateCreated", time: "TimeCreated"},
+}
+
+// CaptureTime returns the first valid date of DateTimeOriginal, CreateDate and the IPTC
+// DateCreated, including their sub second and offset tags. ValueNotFound is returned if there
+// is no date and otherwise the error of the last date that could not be parsed
+func (ed *ExifData) CaptureTime() (CaptureTime, error) {
+	sources := append(append(append([]dateSource{}, originalDates...), createDates...), iptcDates...)
+	return ed.dateTime(sources)
+}
+
+// dateTime returns the first of sources that can be parsed
+func (ed *ExifData) dateTime(sources []dateSource) (CaptureTime, error) {
+	err := json.ValueNotFound
+	for _, src := range sources {
+		dt, e := json.GetString(src.tag, ed.Time)
+		if e != nil {
+			continue
+		}
+		subSec := json.GetOr(src.subSec, ed.Time, "")
+		offset := json.GetOr(src.offset, ed.Time, "")
+		ts, e := json.ParseExifDateTime(dt, subSec, offset)
+		if tm := json.GetOr(src.time, ed.Time, ""); e == nil && tm != "" && ts.Precision == json.PrecisionDay {
+			ts, e = json.ParseExifDateTime(dt+" "+tm, subSec, offset)
+		}
+		if e != nil {
+			err = e
+			continue
+		}
+		return CaptureTime{Timestamp: ts, Tag: src.tag}, nil
+	}
+	return CaptureTime{}, err
+}
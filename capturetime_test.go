@@ -0,0 +1,50 @@
+package mexif
+
+import (
+	"errors"
+	"github.com/msvens/mexif/json"
+	"testing"
+	"time"
+)
+
+func TestCaptureTime(t *testing.T) {
+	tests := []struct {
+		data      string
+		expected  string
+		tag       string
+		precision json.Precision
+		zone      bool
+	}{
+		{`{"SubSecDateTimeOriginal":"2020:01:01 15:01:01.25+01:00","DateTimeOriginal":"2020:01:01 15:01:01"}`,
+			"2020-01-01T15:01:01.25+01:00", "SubSecDateTimeOriginal", json.PrecisionSubSecond, true},
+		{`{"DateTimeOriginal":"2020:01:01 15:01:01","SubSecTimeOriginal":"5","OffsetTimeOriginal":"-05:00"}`,
+			"2020-01-01T15:01:01.5-05:00", "DateTimeOriginal", json.PrecisionSubSecond, true},
+		{`{"DateTimeOriginal":"0000:00:00 00:00:00","CreateDate":"2020:01:01 15:01:01"}`,
+			"2020-01-01T15:01:01Z", "CreateDate", json.PrecisionSecond, false},
+		{`{"DateCreated":"2020:01:01","TimeCreated":"15:01:01+02:00"}`,
+			"2020-01-01T15:01:01+02:00", "DateCreated", json.PrecisionSecond, true},
+		{`{"DateCreated":"2020:01:01"}`, "2020-01-01T00:00:00Z", "DateCreated", json.PrecisionDay, false},
+	}
+	for _, test := range tests {
+		data := testExifData(t, `[{"Time":`+test.data+`}]`)
+		ct, err := data.CaptureTime()
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", test.data, err)
+		} else if v := ct.Time.Format(time.RFC3339Nano); v != test.expected || ct.Tag != test.tag ||
+			ct.Precision != test.precision || ct.ZoneKnown != test.zone {
+			t.Errorf("unexpected capture time for %s: %v %+v", test.data, v, ct)
+		}
+	}
+	if _, err := testExifData(t, `[{"Time":{"DateTimeOriginal":"0000:00:00 00:00:00"}}]`).CaptureTime(); !errors.Is(err, json.ZeroDate) {
+		t.Errorf("expected ZeroDate got %v", err)
+	}
+	if _, err := testExifData(t, `[{}]`).CaptureTime(); !errors.Is(err, json.ValueNotFound) {
+		t.Errorf("expected ValueNotFound got %v", err)
+	}
+
+	c := NewExifCompact(testExifData(t, `[{"Time":{"DateTimeOriginal":"2020:01:01 15:01:01Z","SubSecTimeOriginal":"25",
+		"CreateDate":"2021:01:01 15:01:01","ModifyDate":"0000:00:00 00:00:00"}}]`))
+	if c.OriginalDate.Format(time.RFC3339Nano) != "2020-01-01T15:01:01.25Z" || !c.ModifyDate.IsZero() {
+		t.Errorf("unexpected dates %v %v", c.OriginalDate, c.ModifyDate)
+	}
+}
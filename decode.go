@@ -0,0 +1,265 @@
+package mexif
+
+import (
+	"fmt"
+	"github.com/msvens/mexif/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// DecodeTag is the struct tag read by Decode
+const DecodeTag = "exif"
+
+var timeType = reflect.TypeOf(time.Time{})
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// DecodeError lists the fields Decode could not set, named by their path in the struct, e.g.
+// Lens.Model or Faces[0].Name. All other fields are set
+type DecodeError struct {
+	Missing  []string
+	Mistyped []string
+}
+
+func (e *DecodeError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Mistyped) > 0 {
+		parts = append(parts, "mistyped "+strings.Join(e.Mistyped, ", "))
+	}
+	return "decode: " + strings.Join(parts, "; ")
+}
+
+// fieldTag is a parsed exif struct tag. paths holds the tag followed by its fallbacks
+type fieldTag struct {
+	paths    []string
+	group    string
+	offset   string
+	optional bool
+}
+
+// lookupFunc returns the value of a tag name or dotted path
+type lookupFunc func(path string) (interface{}, bool)
+
+type decoder struct {
+	err    DecodeError
+	tagErr error
+}
+
+// Decode sets the fields of the struct v points to from data. Fields are selected with exif
+// struct tags, e.g.
+//
+//	Make  string    `exif:"Camera.Make"`
+//	Lens  string    `exif:"LensModel,group=Image,fallback=Lens"`
+//	Taken time.Time `exif:"DateTimeOriginal,offset=OffsetTimeOriginal"`
+//	Title string    `exif:"Title,optional"`
+//
+// A tag without a group is searched for in all groups, see ExifData.Find, and a dotted path is
+// read with ExifData.Query. fallback, which can be repeated, names tags to use if the first is
+// missing and offset names the tag with the UTC offset of a time. Values are converted as
+// json.Get converts them. Slices are set from arrays or single values and structs from
+// objects, where the tags of the nested struct are relative to the object. Untagged struct
+// fields are decoded from the same tags as their parent and other untagged fields are left
+// unchanged. A *DecodeError is returned if any field that is not optional is missing or any
+// value can not be converted
+func Decode(data *ExifData, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("decode: expected a pointer to a struct got %T", v)
+	}
+	root := data.Object()
+	lookup := func(path string) (interface{}, bool) {
+		if strings.ContainsAny(path, ".[") {
+			v, err := data.query(root, path)
+			return v, err == nil
+		}
+		v, _, found := data.Find(path)
+		return v, found
+	}
+	d := decoder{}
+	d.decodeStruct(rv.Elem(), lookup, "")
+	if d.tagErr != nil {
+		return d.tagErr
+	}
+	if len(d.err.Missing) > 0 || len(d.err.Mistyped) > 0 {
+		return &d.err
+	}
+	return nil
+}
+
+func parseFieldTag(tag string, name string) (fieldTag, error) {
+	parts := strings.Split(tag, ",")
+	ret := fieldTag{paths: []string{parts[0]}}
+	if parts[0] == "" {
+		ret.paths[0] = name
+	}
+	for _, p := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+		switch {
+		case key == "group" && value != "":
+			ret.group = value
+		case key == "fallback" && value != "":
+			ret.paths = append(ret.paths, value)
+		case key == "offset" && value != "":
+			ret.offset = value
+		case key == "optional" && value == "":
+			ret.optional = true
+		default:
+			return ret, fmt.Errorf("decode: invalid exif tag option %q", p)
+		}
+	}
+	return ret, nil
+}
+
+// path returns p in the group of the tag unless p already is a path
+func (ft fieldTag) path(p string) string {
+	if ft.group == "" || strings.ContainsAny(p, ".[") {
+		return p
+	}
+	return ft.group + "." + p
+}
+
+func (ft fieldTag) value(lookup lookupFunc) (interface{}, bool) {
+	for _, p := range ft.paths {
+		if v, found := lookup(ft.path(p)); found {
+			if ft.offset == "" {
+				return v, true
+			}
+			//the offset is only added to dates that do not have one
+			offset, _ := lookup(ft.path(ft.offset))
+			dt, ok1 := v.(string)
+			o, ok2 := offset.(string)
+			if ok1 && ok2 {
+				if ts, err := json.ParseTimestamp(dt); err == nil && !ts.ZoneKnown {
+					if _, err := json.ParseDateTime(dt, o); err == nil {
+						return dt + " " + o, true
+					}
+				}
+			}
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func (d *decoder) decodeStruct(v reflect.Value, lookup lookupFunc, prefix string) {
+	t := v.Type()
+	for i := 0; i < t.NumField() && d.tagErr == nil; i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := prefix + sf.Name
+		tag, tagged := sf.Tag.Lookup(DecodeTag)
+		if tag == "-" {
+			continue
+		}
+		f := v.Field(i)
+		if !tagged {
+			if f.Kind() == reflect.Pointer && f.Type().Elem().Kind() == reflect.Struct && f.Type().Elem() != timeType {
+				if f.IsNil() {
+					f.Set(reflect.New(f.Type().Elem()))
+				}
+				f = f.Elem()
+			}
+			if f.Kind() == reflect.Struct && f.Type() != timeType {
+				d.decodeStruct(f, lookup, name+".")
+			}
+			continue
+		}
+		ft, err := parseFieldTag(tag, sf.Name)
+		if err != nil {
+			d.tagErr = fmt.Errorf("%w on field %s", err, name)
+			return
+		}
+		val, found := ft.value(lookup)
+		switch {
+		case !found:
+			if !ft.optional {
+				d.err.Missing = append(d.err.Missing, name)
+			}
+		case !d.set(f, val, name):
+			d.err.Mistyped = append(d.err.Mistyped, name)
+		}
+	}
+}
+
+// set converts v to the type of f and sets f. False is returned if v can not be converted
+func (d *decoder) set(f reflect.Value, v interface{}, name string) bool {
+	switch f.Type() {
+	case timeType:
+		return setAs[time.Time](f, v)
+	case durationType:
+		return setAs[time.Duration](f, v)
+	}
+	switch f.Kind() {
+	case reflect.String:
+		return setAs[string](f, v)
+	case reflect.Bool:
+		return setAs[bool](f, v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := json.Convert[int64](v)
+		if err != nil || f.OverflowInt(n) {
+			return false
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := json.Convert[uint](v)
+		if err != nil || f.OverflowUint(uint64(n)) {
+			return false
+		}
+		f.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		n, err := json.Convert[float64](v)
+		if err != nil || f.OverflowFloat(n) {
+			return false
+		}
+		f.SetFloat(n)
+	case reflect.Pointer:
+		p := reflect.New(f.Type().Elem())
+		if !d.set(p.Elem(), v, name) {
+			return false
+		}
+		f.Set(p)
+	case reflect.Slice:
+		arr, ok := v.([]interface{})
+		if !ok {
+			arr = []interface{}{v}
+		}
+		s := reflect.MakeSlice(f.Type(), len(arr), len(arr))
+		for i, e := range arr {
+			if !d.set(s.Index(i), e, fmt.Sprintf("%s[%d]", name, i)) {
+				return false
+			}
+		}
+		f.Set(s)
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		d.decodeStruct(f, func(path string) (interface{}, bool) {
+			v, err := json.Query(obj, path)
+			return v, err == nil
+		}, name+".")
+	case reflect.Interface:
+		if v == nil || f.NumMethod() > 0 {
+			return false
+		}
+		f.Set(reflect.ValueOf(v))
+	default:
+		return false
+	}
+	return true
+}
+
+func setAs[T json.Value](f reflect.Value, v interface{}) bool {
+	c, err := json.Convert[T](v)
+	if err != nil {
+		return false
+	}
+	f.Set(reflect.ValueOf(c).Convert(f.Type()))
+	return true
+}
@@ -0,0 +1,113 @@
+package mexif
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testLens struct {
+	Model string  `exif:"LensModel,group=Image,fallback=Lens"`
+	Focal float64 `exif:"FocalLength"`
+}
+
+type testFace struct {
+	Name string `exif:"Name"`
+	Area []int  `exif:"Area"`
+}
+
+type testPhoto struct {
+	Make     string        `exif:"Camera.Make"`
+	ISO      uint16        `exif:"ISO"`
+	Flash    bool          `exif:"FlashFired"`
+	Taken    time.Time     `exif:"DateTimeOriginal,offset=OffsetTimeOriginal"`
+	Duration time.Duration `exif:"Duration,optional"`
+	Keywords []string      `exif:"Keywords"`
+	First    string        `exif:"Other.Keywords[0]"`
+	Rating   *int          `exif:"Rating"`
+	Faces    []testFace    `exif:"Faces"`
+	Lens     testLens
+	Title    string `exif:",optional"`
+	Skipped  string
+	Ignored  string `exif:"-"`
+}
+
+func testExifData(t *testing.T, s string) *ExifData {
+	t.Helper()
+	return NewExifData(testRoots(t, s)[0])
+}
+
+func TestDecode(t *testing.T) {
+	data := testExifData(t, `[{"Camera":{"Make":"Canon","ISO":"200","FlashFired":"Yes","FocalLength":"50.0"},
+		"Image":{"Lens":"EF 50mm"},
+		"Time":{"DateTimeOriginal":"2020:01:01 15:01:01","OffsetTimeOriginal":"+01:00"},
+		"Author":{"Rating":4},
+		"Other":{"Keywords":["a","b"],"Faces":[{"Name":"Anna","Area":[1,2,3,4]}],"Title":"Sunset"}}]`)
+	var p testPhoto
+	if err := Decode(data, &p); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	rating := 4
+	expected := testPhoto{
+		Make: "Canon", ISO: 200, Flash: true, Taken: time.Date(2020, 1, 1, 15, 1, 1, 0, time.FixedZone("", 3600)),
+		Keywords: []string{"a", "b"}, First: "a", Rating: &rating,
+		Faces: []testFace{{Name: "Anna", Area: []int{1, 2, 3, 4}}},
+		Lens:  testLens{Model: "EF 50mm", Focal: 50}, Title: "Sunset",
+	}
+	if !p.Taken.Equal(expected.Taken) || p.Taken.Format(time.RFC3339) != "2020-01-01T15:01:01+01:00" {
+		t.Errorf("expected %v got %v", expected.Taken, p.Taken)
+	}
+	p.Taken = expected.Taken
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("expected %+v got %+v", expected, p)
+	}
+}
+
+func TestDecodeZonedOffset(t *testing.T) {
+	data := testExifData(t, `[{"Time":{"SubSecDateTimeOriginal":"2021:06:01 12:20:30.12+02:00",
+		"DateTimeOriginal":"2021:06:01 12:20:30","OffsetTimeOriginal":"+02:00"}}]`)
+	var p struct {
+		Taken    time.Time `exif:"Time.SubSecDateTimeOriginal,offset=OffsetTimeOriginal"`
+		Original time.Time `exif:"Time.DateTimeOriginal,offset=OffsetTimeOriginal"`
+	}
+	if err := Decode(data, &p); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if v := p.Taken.Format(time.RFC3339Nano); v != "2021-06-01T12:20:30.12+02:00" {
+		t.Errorf("expected 2021-06-01T12:20:30.12+02:00 got %v", v)
+	}
+	if v := p.Original.Format(time.RFC3339Nano); v != "2021-06-01T12:20:30+02:00" {
+		t.Errorf("expected 2021-06-01T12:20:30+02:00 got %v", v)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	data := testExifData(t, `[{"Camera":{"Make":"Canon","ISO":-1,"FlashFired":"maybe"},
+		"Other":{"Keywords":"a","Faces":[{"Area":"x"}]}}]`)
+	var p testPhoto
+	err := Decode(data, &p)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected DecodeError got %v", err)
+	}
+	missing := []string{"Taken", "First", "Rating", "Faces[0].Name", "Lens.Model", "Lens.Focal"}
+	if !reflect.DeepEqual(decodeErr.Missing, missing) {
+		t.Errorf("expected missing %v got %v", missing, decodeErr.Missing)
+	}
+	if mistyped := []string{"ISO", "Flash", "Faces[0].Area"}; !reflect.DeepEqual(decodeErr.Mistyped, mistyped) {
+		t.Errorf("expected mistyped %v got %v", mistyped, decodeErr.Mistyped)
+	}
+	if p.Make != "Canon" || len(p.Keywords) != 1 || p.Keywords[0] != "a" {
+		t.Errorf("expected other fields to be set got %+v", p)
+	}
+	if err := Decode(data, p); err == nil || errors.As(err, &decodeErr) {
+		t.Errorf("expected error for non pointer got %v", err)
+	}
+	var bad struct {
+		Make string `exif:"Make,unknown"`
+	}
+	if err := Decode(data, &bad); err == nil || errors.As(err, &decodeErr) {
+		t.Errorf("expected error for invalid tag got %v", err)
+	}
+}
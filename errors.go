@@ -0,0 +1,148 @@
+package mexif
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/msvens/mexif/json"
+	"github.com/msvens/mexif/native"
+	"strings"
+)
+
+const errorPrefix = "Error: "
+const warningPrefix = "Warning: "
+
+var ErrFileNotFound = errors.New("file not found")
+
+// ErrFileUnreadable is returned when a file exists but can not be opened, e.g. because of its
+// permissions or because it is a directory
+var ErrFileUnreadable = errors.New("file can not be read")
+
+// ErrUnsupportedFormat is the same error as native.ErrUnsupportedFormat so both backends can
+// be checked with errors.Is
+var ErrUnsupportedFormat = native.ErrUnsupportedFormat
+var ErrExifTool = errors.New("exiftool error")
+
+// ExifToolError is an error reported by exiftool for a file, either on stderr or as the Error
+// tag of the ExifTool group. It matches ErrFileNotFound, ErrFileUnreadable, ErrUnsupportedFormat
+// or ErrExifTool with errors.Is.
+type ExifToolError struct {
+	Path    string
+	Message string
+	Err     error
+}
+
+func newExifToolError(path string, msg string) *ExifToolError {
+	e := ExifToolError{Path: path, Message: msg, Err: ErrExifTool}
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "file not found"):
+		e.Err = ErrFileNotFound
+	//exiftool reports any failure to open an existing file this way
+	case strings.Contains(lower, "error opening file"):
+		e.Err = ErrFileUnreadable
+	//an empty file has no format that could be read
+	case strings.Contains(lower, "unknown file type"), strings.Contains(lower, "file format error"),
+		strings.Contains(lower, "unsupported file type"), strings.Contains(lower, "file is empty"):
+		e.Err = ErrUnsupportedFormat
+	}
+	return &e
+}
+
+func (e *ExifToolError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("exiftool: %s", e.Message)
+	}
+	return fmt.Sprintf("exiftool: %s - %s", e.Message, e.Path)
+}
+
+func (e *ExifToolError) Unwrap() error {
+	return e.Err
+}
+
+// response is the output of a single -execute. Depending on the output mode stdout is either
+// kept in stdout or decoded into roots
+type response struct {
+	stdout    []byte
+	roots     []map[string]interface{}
+	decodeErr error
+	stderr    []byte
+}
+
+// messages returns the text of all stderr lines starting with prefix, with the trailing
+// " - path" that exiftool adds removed
+func (r *response) messages(prefix string) []string {
+	var ret []string
+	scanner := bufio.NewScanner(bytes.NewReader(r.stderr))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		line = strings.TrimPrefix(line, prefix)
+		if i := strings.LastIndex(line, " - "); i > 0 {
+			line = line[:i]
+		}
+		ret = append(ret, line)
+	}
+	return ret
+}
+
+// err returns the first error exiftool reported on stderr when it produced no output
+func (r *response) err(path string) error {
+	if len(bytes.TrimSpace(r.stdout)) > 0 || len(r.roots) > 0 || r.decodeErr != nil {
+		return nil
+	}
+	if errs := r.messages(errorPrefix); len(errs) > 0 {
+		return newExifToolError(path, errs[0])
+	}
+	return nil
+}
+
+func (r *response) first() (map[string]interface{}, error) {
+	if r.decodeErr != nil {
+		return nil, r.decodeErr
+	}
+	if len(r.roots) < 1 {
+		return nil, fmt.Errorf("no data")
+	}
+	return r.roots[0], nil
+}
+
+func (r *response) exifData(path string) (*ExifData, error) {
+	root, err := r.first()
+	if err != nil {
+		return nil, err
+	}
+	return newExifData(path, root, r.messages(warningPrefix))
+}
+
+// fileMessages is like messages but groups the messages by the file they were reported for
+func (r *response) fileMessages(prefix string, paths []string) map[string][]string {
+	ret := map[string][]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(r.stderr))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		line = strings.TrimPrefix(line, prefix)
+		for _, p := range paths {
+			if strings.HasSuffix(line, " - "+p) {
+				ret[p] = append(ret[p], strings.TrimSuffix(line, " - "+p))
+				break
+			}
+		}
+	}
+	return ret
+}
+
+func newExifData(path string, root map[string]interface{}, warnings []string) (*ExifData, error) {
+	data := NewExifData(root)
+	if msg, err := json.GetString("Error", data.ExifTool); err == nil {
+		return nil, newExifToolError(path, msg)
+	}
+	data.Warnings = append(data.Warnings, warnings...)
+	return data, nil
+}
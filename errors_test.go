@@ -0,0 +1,66 @@
+package mexif
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func testRoots(t *testing.T, s string) []map[string]interface{} {
+	t.Helper()
+	var roots []map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &roots); err != nil {
+		t.Fatalf("invalid test json: %v", err)
+	}
+	return roots
+}
+
+func TestResponseErr(t *testing.T) {
+	resp := response{stderr: []byte("Error: File not found - missing.jpg\n")}
+	if err := resp.err("missing.jpg"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("expected ErrFileNotFound got %v", err)
+	}
+	resp = response{stdout: []byte("[]"), stderr: []byte("Error: File not found - missing.jpg\n")}
+	if err := resp.err("missing.jpg"); err != nil {
+		t.Errorf("expected no error when there is output got %v", err)
+	}
+}
+
+func TestNewExifToolError(t *testing.T) {
+	tests := map[string]error{
+		"File not found":                     ErrFileNotFound,
+		"Error opening file":                 ErrFileUnreadable,
+		"Unknown file type":                  ErrUnsupportedFormat,
+		"File format error":                  ErrUnsupportedFormat,
+		"File is empty":                      ErrUnsupportedFormat,
+		"Error reading OtherImageStart data": ErrExifTool,
+	}
+	for msg, expected := range tests {
+		if err := newExifToolError("a.jpg", msg); !errors.Is(err, expected) {
+			t.Errorf("expected %v for %s got %v", expected, msg, err.Err)
+		}
+	}
+	if err := newExifToolError("a.jpg", "Error opening file"); errors.Is(err, ErrFileNotFound) {
+		t.Errorf("expected an unreadable file not to match ErrFileNotFound")
+	}
+}
+
+func TestResponseExifData(t *testing.T) {
+	resp := response{roots: testRoots(t, `[{"SourceFile":"a.xyz","ExifTool":{"Error":"Unknown file type"}}]`)}
+	var exifErr *ExifToolError
+	if _, err := resp.exifData("a.xyz"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat got %v", err)
+	} else if !errors.As(err, &exifErr) || exifErr.Path != "a.xyz" {
+		t.Errorf("expected ExifToolError for a.xyz got %v", err)
+	}
+
+	resp = response{
+		roots:  testRoots(t, `[{"SourceFile":"a.jpg","ExifTool":{"Warning":"Bad MakerNotes"}}]`),
+		stderr: []byte("Warning: Truncated file - a.jpg\n"),
+	}
+	if d, err := resp.exifData("a.jpg"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if len(d.Warnings) != 2 || d.Warnings[0] != "Bad MakerNotes" || d.Warnings[1] != "Truncated file" {
+		t.Errorf("unexpected warnings %v", d.Warnings)
+	}
+}
@@ -2,6 +2,8 @@ package mexif
 
 import (
 	"github.com/msvens/mexif/json"
+	"math"
+	"strings"
 	"time"
 )
 
@@ -18,10 +20,13 @@ type ExifCompact struct {
 	LensMake                string  `json:"lensMake,omitempty"`
 	FocalLength             string  `json:"focalLength,omitempty"`
 	FocalLengthIn35mmFormat string  `json:"focalLengthIn35mmFormat,omitempty"`
+	FocalLengthMM           float64 `json:"focalLengthMM,omitempty"`
+	FocalLength35mm         float64 `json:"focalLength35mm,omitempty"`
 	MaxApertureValue        float32 `json:"maxApertureValue,omitempty"`
 	Flash                   string  `json:"flash,omitempty"`
 
 	ExposureTime         string  `json:"exposureTime,omitempty"`
+	ExposureSeconds      float64 `json:"exposureSeconds,omitempty"`
 	ExposureCompensation float32 `json:"exposureCompensationn,omitempty"`
 	ExposureProgram      string  `json:"exposoureProgram,omitempty"`
 	FNumber              float32 `json:"fNumber,omitempty"`
@@ -45,55 +50,94 @@ type ExifCompact struct {
 func NewExifCompact(data *ExifData) *ExifCompact {
 	ec := ExifCompact{}
 
-	_ = json.ScanString("Title", data.Image, &ec.Title)
-
-	//Keywords can either come as an array or strong
-	kw := data.Other["Keywords"]
-	if kw != nil {
-		t := json.TypeOf(kw)
-		if t == json.JString {
-			ec.Keywords = append(ec.Keywords, kw.(string))
-		} else if t == json.JArr {
-			for _, v := range kw.([]interface{}) {
-				ec.Keywords = append(ec.Keywords, v.(string))
-			}
+	_ = json.Scan("Title", data.Image, &ec.Title)
+
+	//Keywords can either come as an array or a single value
+	_ = json.Scan("Keywords", data.Other, &ec.Keywords)
+
+	_ = json.Scan("Software", data.Image, &ec.Software)
+	_ = json.Scan("Rating", data.Image, &ec.Rating)
+
+	_ = json.Scan("Make", data.Camera, &ec.CameraMake)
+	_ = json.Scan("Model", data.Camera, &ec.CameraModel)
+
+	_ = json.Scan("LensInfo", data.Image, &ec.LensInfo)
+	_ = json.Scan("LensModel", data.Image, &ec.LensModel)
+	_ = json.Scan("LensMake", data.Image, &ec.LensMake)
+
+	_ = json.Scan("FocalLength", data.Camera, &ec.FocalLength)
+	_ = json.Scan("FocalLengthIn35mmFormat", data.Camera, &ec.FocalLengthIn35mmFormat)
+	_ = json.ScanUnit("FocalLength", "mm", data.Camera, &ec.FocalLengthMM)
+	_ = json.ScanUnit("FocalLengthIn35mmFormat", "mm", data.Camera, &ec.FocalLength35mm)
+	//Without FocalLengthIn35mmFormat the composite tags give the 35mm equivalent
+	if ec.FocalLength35mm == 0 {
+		efl := json.GetOr("FocalLength35efl", data.Camera, "")
+		if _, equivalent, found := strings.Cut(efl, "equivalent:"); found {
+			ec.FocalLength35mm, _, _ = json.ParseUnit(equivalent)
+		} else if scale, err := json.Get[float64]("ScaleFactor35efl", data.Camera); err == nil {
+			ec.FocalLength35mm = scale * ec.FocalLengthMM
 		}
 	}
+	_ = json.Scan("MaxApertureValue", data.Camera, &ec.MaxApertureValue)
+	_ = json.Scan("Flash", data.Camera, &ec.Flash)
+
+	_ = json.Scan("ExposureTime", data.Image, &ec.ExposureTime)
+	_ = json.ScanRational("ExposureTime", data.Image, &ec.ExposureSeconds)
+	//exiftool prints the exposure compensation as a fraction, e.g. -1/3
+	if ev, err := json.GetRational("ExposureCompensation", data.Image); err == nil {
+		ec.ExposureCompensation = float32(ev)
+	}
+	_ = json.Scan("ExposureProgram", data.Camera, &ec.ExposureProgram)
+	_ = json.Scan("FNumber", data.Image, &ec.FNumber)
+	_ = json.Scan("ISO", data.Image, &ec.ISO)
+	_ = json.Scan("ColorSpace", data.Image, &ec.ColorSpace)
+	_ = json.Scan("XResolution", data.Image, &ec.XResolution)
+	_ = json.Scan("YResolution", data.Image, &ec.YResolution)
+	//PNG files have the resolution in pixels per meter
+	if json.GetOr("PixelUnits", data.Image, "") == "meters" && ec.XResolution == 0 {
+		if x, err := json.Get[float64]("PixelsPerUnitX", data.Image); err == nil {
+			ec.XResolution = uint(math.Round(x * 0.0254))
+		}
+		if y, err := json.Get[float64]("PixelsPerUnitY", data.Image); err == nil {
+			ec.YResolution = uint(math.Round(y * 0.0254))
+		}
+	}
+	_ = json.Scan("ImageWidth", data.Image, &ec.ImageWidth)
+	_ = json.Scan("ImageHeight", data.Image, &ec.ImageHeight)
+	//Videos have the image size in the Video group
+	if ec.ImageWidth == 0 && ec.ImageHeight == 0 {
+		_ = json.Scan("ImageWidth", data.Video, &ec.ImageWidth)
+		_ = json.Scan("ImageHeight", data.Video, &ec.ImageHeight)
+	}
 
-	_ = json.ScanString("Software", data.Image, &ec.Software)
-	_ = json.ScanUInt("Rating", data.Image, &ec.Rating)
-
-	_ = json.ScanString("Make", data.Camera, &ec.CameraMake)
-	_ = json.ScanString("Model", data.Camera, &ec.CameraModel)
-
-	_ = json.ScanString("LensInfo", data.Image, &ec.LensInfo)
-	_ = json.ScanString("LensModel", data.Image, &ec.LensModel)
-	_ = json.ScanString("LensMake", data.Image, &ec.LensMake)
-
-	_ = json.ScanString("FocalLength", data.Camera, &ec.FocalLength)
-	_ = json.ScanString("FocalLengthIn35mmFormat", data.Camera, &ec.FocalLengthIn35mmFormat)
-	_ = json.ScanFloat32("MaxApertureValue", data.Camera, &ec.MaxApertureValue)
-	_ = json.ScanString("Flash", data.Camera, &ec.Flash)
-
-	_ = json.ScanString("ExposureTime", data.Image, &ec.ExposureTime)
-	_ = json.ScanFloat32("ExposureCopmensation", data.Image, &ec.ExposureCompensation)
-	_ = json.ScanString("ExposureProgram", data.Camera, &ec.ExposureProgram)
-	_ = json.ScanFloat32("FNumber", data.Image, &ec.FNumber)
-	_ = json.ScanUInt("ISO", data.Image, &ec.ISO)
-	_ = json.ScanString("ColorSpace", data.Image, &ec.ColorSpace)
-	_ = json.ScanUInt("XResolution", data.Image, &ec.XResolution)
-	_ = json.ScanUInt("YResolution", data.Image, &ec.YResolution)
-	_ = json.ScanUInt("ImageWidth", data.Image, &ec.ImageWidth)
-	_ = json.ScanUInt("ImageHeight", data.Image, &ec.ImageHeight)
-
-	_ = json.ScanDateTime("DateTimeOriginal", "OffsetTimeOriginal", data.Time, &ec.OriginalDate)
-	_ = json.ScanDateTime("ModifyDate", "OffsetTime", data.Time, &ec.ModifyDate)
-
-	_ = json.ScanFloat64("GPSLatitude", data.Location, &ec.GPSLatitude)
-	_ = json.ScanFloat64("GPSLongitude", data.Location, &ec.GPSLongitude)
-	_ = json.ScanString("City", data.Location, &ec.City)
-	_ = json.ScanString("Country", data.Location, &ec.Country)
-	_ = json.ScanString("State", data.Location, &ec.State)
+	if ct, err := data.dateTime(originalDates); err == nil {
+		ec.OriginalDate = ct.Time
+	}
+	if ct, err := data.dateTime(modifyDates); err == nil {
+		ec.ModifyDate = ct.Time
+	}
+
+	_ = json.ScanCoordinate("GPSLatitude", "GPSLatitudeRef", data.Location, &ec.GPSLatitude)
+	_ = json.ScanCoordinate("GPSLongitude", "GPSLongitudeRef", data.Location, &ec.GPSLongitude)
+	_ = json.Scan("City", data.Location, &ec.City)
+	_ = json.Scan("Country", data.Location, &ec.Country)
+	_ = json.Scan("State", data.Location, &ec.State)
+
+	//Files with only IPTC-IIM metadata use the IPTC names
+	if ec.Title == "" {
+		_ = json.Scan("ObjectName", data.Other, &ec.Title)
+	}
+	if ec.Country == "" {
+		_ = json.Scan("Country-PrimaryLocationName", data.Location, &ec.Country)
+	}
+	if ec.State == "" {
+		_ = json.Scan("Province-State", data.Location, &ec.State)
+	}
+	if ec.OriginalDate.IsZero() {
+		if ct, err := data.dateTime(iptcDates); err == nil {
+			ec.OriginalDate = ct.Time
+		}
+	}
 
 	return &ec
 }
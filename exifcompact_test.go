@@ -0,0 +1,18 @@
+package mexif
+
+import (
+	"testing"
+)
+
+func TestNewExifCompactUnits(t *testing.T) {
+	c := NewExifCompact(testExifData(t, `[{"Camera":{"FocalLength":"35.0 mm","FocalLength35efl":"35.0 mm (35 mm equivalent: 52.5 mm)"},
+		"Image":{"ExposureTime":"1/250","ExposureCompensation":"-1/3"}}]`))
+	if c.ExposureTime != "1/250" || c.ExposureSeconds != 0.004 || c.FocalLength != "35.0 mm" || c.FocalLengthMM != 35 ||
+		c.FocalLength35mm != 52.5 || c.ExposureCompensation != float32(-1.0/3) {
+		t.Errorf("unexpected compact data %+v", c)
+	}
+	c = NewExifCompact(testExifData(t, `[{"Camera":{"FocalLength":12,"ScaleFactor35efl":2.0},"Image":{"ExposureTime":2,"ExposureCompensation":0.7}}]`))
+	if c.ExposureSeconds != 2 || c.ExposureCompensation != 0.7 || c.FocalLengthMM != 12 || c.FocalLength35mm != 24 {
+		t.Errorf("unexpected compact data %+v", c)
+	}
+}
@@ -0,0 +1,166 @@
+package json
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ZeroDate is returned for placeholder dates such as 0000:00:00 00:00:00
+var ZeroDate = errors.New("Zero Date")
+
+// Precision is the smallest unit of a parsed date and time
+type Precision int
+
+const (
+	PrecisionYear Precision = iota
+	PrecisionMonth
+	PrecisionDay
+	PrecisionMinute
+	PrecisionSecond
+	PrecisionSubSecond
+)
+
+// Timestamp is a parsed date and time together with how much of it the value contained
+type Timestamp struct {
+	Time      time.Time
+	Precision Precision
+	//ZoneKnown is false if the value had no time zone and Time is assumed to be in UTC
+	ZoneKnown bool
+}
+
+// dateTime matches exif dates, e.g. 2020:01:01 15:01:01.25+01:00, and ISO 8601 dates, e.g.
+// 2020-01-01T15:01Z. Partial dates like 2020:01 and 2020 are accepted as well
+var dateTime = regexp.MustCompile(`^(\d{4})(?:[:-](\d{2})(?:[:-](\d{2}))?)?` +
+	`(?:(?:\s+|T)(\d{2}):(\d{2})(?::(\d{2})(?:[.,](\d+))?)?)?\s*(Z|[+-]\d{2}:?\d{2})?$`)
+
+// iptcDate matches the CCYYMMDD dates of IPTC-IIM
+var iptcDate = regexp.MustCompile(`^(\d{4})(\d{2})(\d{2})$`)
+
+var zone = regexp.MustCompile(`^(?:Z|([+-])(\d{2}):?(\d{2}))$`)
+
+// ParseTimestamp parses exif, IPTC and ISO 8601 dates with optional time, fractional seconds
+// and time zone. Months and days printed as 00 by exiftool are treated as missing. ZeroDate is
+// returned for placeholders and IncorrectType for anything that is not a valid date
+func ParseTimestamp(s string) (Timestamp, error) {
+	s = strings.TrimSpace(s)
+	if strings.Trim(s, ":-0 ") == "" {
+		return Timestamp{}, ZeroDate
+	}
+	match := dateTime.FindStringSubmatch(s)
+	if match == nil {
+		if match = iptcDate.FindStringSubmatch(s); match == nil {
+			return Timestamp{}, IncorrectType
+		}
+	}
+	for len(match) < 9 {
+		match = append(match, "")
+	}
+	num := func(i int) int {
+		n, _ := strconv.Atoi(match[i])
+		return n
+	}
+	year, month, day := num(1), num(2), num(3)
+	if year == 0 {
+		return Timestamp{}, ZeroDate
+	}
+	ts := Timestamp{Precision: PrecisionDay}
+	switch {
+	case month == 0:
+		ts.Precision, month, day = PrecisionYear, 1, 1
+	case day == 0:
+		ts.Precision, day = PrecisionMonth, 1
+	}
+	hour, minute, sec, nsec := 0, 0, 0, 0
+	if match[4] != "" && ts.Precision == PrecisionDay {
+		hour, minute, sec = num(4), num(5), num(6)
+		ts.Precision = PrecisionMinute
+		if match[6] != "" {
+			ts.Precision = PrecisionSecond
+		}
+		if match[7] != "" {
+			ts.Precision = PrecisionSubSecond
+			nsec = fraction(match[7])
+		}
+	}
+	loc := time.UTC
+	if match[8] != "" {
+		var ok bool
+		if loc, ok = parseZone(match[8]); !ok {
+			return Timestamp{}, IncorrectType
+		}
+		ts.ZoneKnown = true
+	}
+	ts.Time = time.Date(year, time.Month(month), day, hour, minute, sec, nsec, loc)
+	if ts.Time.Month() != time.Month(month) || ts.Time.Day() != day || ts.Time.Hour() != hour ||
+		ts.Time.Minute() != minute || ts.Time.Second() != sec {
+		return Timestamp{}, IncorrectType
+	}
+	return ts, nil
+}
+
+// ParseExifDateTime parses dt and adds subSec, e.g. the SubSecTimeOriginal tag, and offset,
+// e.g. the OffsetTimeOriginal tag, unless dt already has fractional seconds or a time zone
+func ParseExifDateTime(dt string, subSec string, offset string) (Timestamp, error) {
+	ts, err := ParseTimestamp(dt)
+	if err != nil {
+		return ts, err
+	}
+	if subSec = strings.TrimSpace(subSec); subSec != "" && ts.Precision == PrecisionSecond {
+		if _, err := strconv.ParseUint(subSec, 10, 64); err != nil {
+			return Timestamp{}, IncorrectType
+		}
+		ts.Time = ts.Time.Add(time.Duration(fraction(subSec)))
+		ts.Precision = PrecisionSubSecond
+	}
+	if offset = strings.TrimSpace(offset); offset != "" && !ts.ZoneKnown && ts.Precision >= PrecisionMinute {
+		loc, ok := parseZone(offset)
+		if !ok {
+			return Timestamp{}, IncorrectType
+		}
+		t := ts.Time
+		ts.Time = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+		ts.ZoneKnown = true
+	}
+	return ts, nil
+}
+
+func GetTimestamp(field string, obj JSONObject) (Timestamp, error) {
+	s, err := GetString(field, obj)
+	if err != nil {
+		return Timestamp{}, err
+	}
+	return ParseTimestamp(s)
+}
+
+// fraction returns the nanoseconds of the fractional second digits
+func fraction(digits string) int {
+	if len(digits) > 9 {
+		digits = digits[:9]
+	}
+	n, _ := strconv.Atoi(digits + strings.Repeat("0", 9-len(digits)))
+	return n
+}
+
+// parseZone parses Z or an offset, e.g. +01:00 or -0500
+func parseZone(s string) (*time.Location, bool) {
+	match := zone.FindStringSubmatch(s)
+	if match == nil {
+		return nil, false
+	}
+	if match[1] == "" {
+		return time.UTC, true
+	}
+	hours, _ := strconv.Atoi(match[2])
+	minutes, _ := strconv.Atoi(match[3])
+	if hours > 14 || minutes > 59 {
+		return nil, false
+	}
+	offset := hours*3600 + minutes*60
+	if match[1] == "-" {
+		offset = -offset
+	}
+	return time.FixedZone("", offset), true
+}
@@ -0,0 +1,234 @@
+package json
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Value are the types Get and Scan convert JSON values to
+type Value interface {
+	string | bool | int | int64 | uint | float32 | float64 | []string | time.Duration | time.Time
+}
+
+// Get returns field converted to T. Numbers and numeric strings convert to each other, Yes/No,
+// True/False and On/Off strings and numbers convert to bool and single element arrays convert
+// to their element. Durations are read from seconds or exiftool's h:mm:ss and times are parsed
+// with ParseTimestamp. IncorrectType is returned if field can not be converted
+func Get[T Value](field string, obj JSONObject) (T, error) {
+	v, found := obj[field]
+	if !found {
+		var zero T
+		return zero, ValueNotFound
+	}
+	return Convert[T](v)
+}
+
+// Convert converts a decoded JSON value to T in the same way as Get
+func Convert[T Value](v interface{}) (T, error) {
+	var ret T
+	if err := convert(v, &ret); err != nil {
+		var zero T
+		return zero, err
+	}
+	return ret, nil
+}
+
+// GetOr is like Get but returns def if field is missing or can not be converted
+func GetOr[T Value](field string, obj JSONObject, def T) T {
+	if v, err := Get[T](field, obj); err == nil {
+		return v
+	}
+	return def
+}
+
+// Scan sets val to field converted to T. val is left unchanged on errors
+func Scan[T Value](field string, obj JSONObject, val *T) error {
+	v, err := Get[T](field, obj)
+	if err != nil {
+		return err
+	}
+	*val = v
+	return nil
+}
+
+func GetStrings(field string, obj JSONObject) ([]string, error) {
+	return Get[[]string](field, obj)
+}
+
+func ScanStrings(field string, obj JSONObject, val *[]string) error {
+	return Scan(field, obj, val)
+}
+
+func GetInt64(field string, obj JSONObject) (int64, error) {
+	return Get[int64](field, obj)
+}
+
+func ScanInt64(field string, obj JSONObject, val *int64) error {
+	return Scan(field, obj, val)
+}
+
+func GetDuration(field string, obj JSONObject) (time.Duration, error) {
+	return Get[time.Duration](field, obj)
+}
+
+func ScanDuration(field string, obj JSONObject, val *time.Duration) error {
+	return Scan(field, obj, val)
+}
+
+func GetTime(field string, obj JSONObject) (time.Time, error) {
+	return Get[time.Time](field, obj)
+}
+
+func ScanTime(field string, obj JSONObject, val *time.Time) error {
+	return Scan(field, obj, val)
+}
+
+func convert(v interface{}, val interface{}) error {
+	if arr, ok := v.([]interface{}); ok {
+		if _, strs := val.(*[]string); !strs {
+			if len(arr) != 1 {
+				return IncorrectType
+			}
+			v = arr[0]
+		}
+	}
+	var err error
+	switch p := val.(type) {
+	case *string:
+		*p, err = toString(v)
+	case *bool:
+		*p, err = toBool(v)
+	case *int:
+		var n float64
+		n, err = toNumber(v)
+		*p = int(n)
+	case *int64:
+		var n float64
+		n, err = toNumber(v)
+		*p = int64(n)
+	case *uint:
+		var n float64
+		if n, err = toNumber(v); err == nil && n < 0 {
+			err = IncorrectType
+		}
+		*p = uint(n)
+	case *float32:
+		var n float64
+		n, err = toNumber(v)
+		*p = float32(n)
+	case *float64:
+		*p, err = toNumber(v)
+	case *[]string:
+		*p, err = toStrings(v)
+	case *time.Duration:
+		*p, err = toDuration(v)
+	case *time.Time:
+		*p, err = toTime(v)
+	default:
+		err = IncorrectType
+	}
+	return err
+}
+
+func toString(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	}
+	return "", IncorrectType
+}
+
+func toNumber(v interface{}) (float64, error) {
+	switch t := v.(type) {
+	case float64:
+		return t, nil
+	case string:
+		if n, err := strconv.ParseFloat(strings.TrimSpace(t), 64); err == nil {
+			return n, nil
+		}
+	}
+	return 0, IncorrectType
+}
+
+func toBool(v interface{}) (bool, error) {
+	switch t := v.(type) {
+	case bool:
+		return t, nil
+	case float64:
+		return t != 0, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(t)) {
+		case "yes", "true", "on", "1":
+			return true, nil
+		case "no", "false", "off", "0":
+			return false, nil
+		}
+	}
+	return false, IncorrectType
+}
+
+func toStrings(v interface{}) ([]string, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		arr = []interface{}{v}
+	}
+	ret := make([]string, 0, len(arr))
+	for _, e := range arr {
+		s, err := toString(e)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, s)
+	}
+	return ret, nil
+}
+
+// toDuration reads seconds, e.g. 12.5 or "12.5 s (approx)", or h:mm:ss, e.g. "0:01:23"
+func toDuration(v interface{}) (time.Duration, error) {
+	if n, err := toNumber(v); err == nil {
+		return seconds(n), nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return 0, IncorrectType
+	}
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "(approx)"))
+	if n, err := strconv.ParseFloat(strings.TrimSuffix(s, " s"), 64); err == nil {
+		return seconds(n), nil
+	}
+	if parts := strings.Split(s, ":"); len(parts) > 1 && len(parts) <= 3 {
+		total := 0.0
+		for _, p := range parts {
+			n, err := strconv.ParseFloat(p, 64)
+			if err != nil || n < 0 {
+				return 0, IncorrectType
+			}
+			total = total*60 + n
+		}
+		return seconds(total), nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	return 0, IncorrectType
+}
+
+func seconds(n float64) time.Duration {
+	return time.Duration(math.Round(n * float64(time.Second)))
+}
+
+// toTime reads dates as ParseTimestamp does
+func toTime(v interface{}) (time.Time, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, IncorrectType
+	}
+	ts, err := ParseTimestamp(s)
+	return ts.Time, err
+}
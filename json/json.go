@@ -3,6 +3,10 @@ package json
 import (
 	"errors"
 	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -32,65 +36,31 @@ var IncorrectType = errors.New("Incorrect Type")
 var ValueNotFound = errors.New("Value Not Found")
 
 func GetUInt(field string, obj JSONObject) (uint, error) {
-	n, err := GetNumber(field, obj)
-	if err != nil {
-		return 0, err
-	}
-	if n < 0 {
-		return 0, IncorrectType
-	}
-	return uint(n), nil
+	return Get[uint](field, obj)
 }
 
 func ScanUInt(field string, obj JSONObject, val *uint) error {
-	if v, err := GetUInt(field, obj); err == nil {
-		*val = v
-		return nil
-	} else {
-		return err
-	}
+	return Scan(field, obj, val)
 }
 
 func GetInt(field string, obj JSONObject) (int, error) {
-	n, err := GetNumber(field, obj)
-	if err != nil {
-		return 0, err
-	}
-	return int(n), nil
+	return Get[int](field, obj)
 }
 
 func GetFloat32(field string, obj JSONObject) (float32, error) {
-	n, err := GetNumber(field, obj)
-	if err != nil {
-		return 0, err
-	}
-	return float32(n), nil
+	return Get[float32](field, obj)
 }
 
 func ScanFloat32(field string, obj JSONObject, val *float32) error {
-	if v, err := GetFloat32(field, obj); err == nil {
-		*val = v
-		return nil
-	} else {
-		return err
-	}
+	return Scan(field, obj, val)
 }
 
 func GetFloat64(field string, obj JSONObject) (float64, error) {
-	n, err := GetNumber(field, obj)
-	if err != nil {
-		return 0, err
-	}
-	return float64(n), nil
+	return Get[float64](field, obj)
 }
 
 func ScanFloat64(field string, obj JSONObject, val *float64) error {
-	if v, err := GetFloat64(field, obj); err == nil {
-		*val = v
-		return nil
-	} else {
-		return err
-	}
+	return Scan(field, obj, val)
 }
 
 func GetDateTime(dtField string, offsetField string, obj JSONObject) (time.Time, error) {
@@ -112,27 +82,34 @@ func ScanDateTime(dtField string, offsetField string, obj JSONObject, val *time.
 
 }
 
+// ParseDateTime parses dt as ParseTimestamp does and adds offset unless dt has a time zone
 func ParseDateTime(dt string, offset string) (time.Time, error) {
-	if offset == "" {
-		return time.Parse(ExifDateTime, dt)
-	} else {
-		return time.Parse(ExifDateTimeOffset, dt+" "+offset)
-	}
+	ts, err := ParseExifDateTime(dt, "", offset)
+	return ts.Time, err
 }
 
-func GetBool(field string, obj JSONObject) (bool, error) {
-	if v, f := obj[field]; f {
-		if s, t := v.(bool); t {
-			return s, nil
-		} else {
-			return false, IncorrectType
-		}
+var coordinateNumber = regexp.MustCompile(`\d+(?:\.\d*)?|\.\d+`)
+
+// GetCoordinate reads a GPS coordinate that is either a number or printed by exiftool, e.g.
+// 59 deg 19' 44.46" N. If the value has no hemisphere the optional refField is used.
+func GetCoordinate(field string, refField string, obj JSONObject) (float64, error) {
+	if v, err := GetNumber(field, obj); err == nil {
+		return v, nil
+	} else if err != IncorrectType {
+		return 0, err
+	}
+	c, err := GetString(field, obj)
+	if err != nil {
+		return 0, err
+	}
+	if ref, err := GetString(refField, obj); err == nil && hemisphere(c) == "" {
+		c = c + " " + ref
 	}
-	return false, ValueNotFound
+	return ParseCoordinate(c)
 }
 
-func ScanBool(field string, obj JSONObject, val *bool) error {
-	if v, err := GetBool(field, obj); err == nil {
+func ScanCoordinate(field string, refField string, obj JSONObject, val *float64) error {
+	if v, err := GetCoordinate(field, refField, obj); err == nil {
 		*val = v
 		return nil
 	} else {
@@ -140,6 +117,49 @@ func ScanBool(field string, obj JSONObject, val *bool) error {
 	}
 }
 
+// ParseCoordinate parses decimal degrees, e.g. -18.0686, or degrees, minutes and seconds
+// followed by an optional hemisphere, e.g. 59 deg 19' 44.46" N or 59 deg 19' 44.46" South
+func ParseCoordinate(c string) (float64, error) {
+	c = strings.TrimSpace(c)
+	sign := 1.0
+	if strings.HasPrefix(c, "-") {
+		sign = -1
+	}
+	switch strings.ToUpper(hemisphere(c)) {
+	case "", "N", "NORTH", "E", "EAST":
+	case "S", "SOUTH", "W", "WEST":
+		sign = -1
+	default:
+		return 0, IncorrectType
+	}
+	parts := coordinateNumber.FindAllString(c, -1)
+	if len(parts) == 0 || len(parts) > 3 {
+		return 0, IncorrectType
+	}
+	ret := 0.0
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return 0, err
+		}
+		ret += v / math.Pow(60, float64(i))
+	}
+	return sign * ret, nil
+}
+
+func hemisphere(c string) string {
+	i := strings.LastIndexAny(c, "0123456789\"'")
+	return strings.TrimSpace(c[i+1:])
+}
+
+func GetBool(field string, obj JSONObject) (bool, error) {
+	return Get[bool](field, obj)
+}
+
+func ScanBool(field string, obj JSONObject, val *bool) error {
+	return Scan(field, obj, val)
+}
+
 func GetArray(field string, obj JSONObject) (JSONArray, error) {
 	if v, f := obj[field]; f {
 		if s, t := v.([]interface{}); t {
@@ -181,43 +201,19 @@ func ScanObject(field string, obj JSONObject, val *JSONObject) error {
 }
 
 func GetNumber(field string, obj JSONObject) (float64, error) {
-	if v, f := obj[field]; f {
-		if s, t := v.(float64); t {
-			return s, nil
-		} else {
-			return 0, IncorrectType
-		}
-	}
-	return 0, ValueNotFound
+	return Get[float64](field, obj)
 }
 
 func ScanNumber(field string, obj JSONObject, val *float64) error {
-	if v, err := GetNumber(field, obj); err == nil {
-		*val = v
-		return nil
-	} else {
-		return err
-	}
+	return Scan(field, obj, val)
 }
 
 func GetString(field string, obj JSONObject) (string, error) {
-	if v, f := obj[field]; f {
-		if s, t := v.(string); t {
-			return s, nil
-		} else {
-			return "", IncorrectType
-		}
-	}
-	return "", ValueNotFound
+	return Get[string](field, obj)
 }
 
 func ScanString(field string, obj JSONObject, val *string) error {
-	if str, err := GetString(field, obj); err == nil {
-		*val = str
-		return nil
-	} else {
-		return err
-	}
+	return Scan(field, obj, val)
 }
 
 func IsType(val interface{}, jsonType JsonType) bool {
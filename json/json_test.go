@@ -231,3 +231,299 @@ func TestScanUint(t *testing.T) {
 		t.Errorf("expected %v, got %v", testuint, n)
 	}
 }
+
+func TestParseCoordinate(t *testing.T) {
+	tests := map[string]float64{
+		"59 deg 19' 30.00\" N": 59.325,
+		"18 deg 4' 12.00\" W":  -18.07,
+		"18 deg 4' 12.00\"":    18.07,
+		"-33.5":                -33.5,
+		"+33.5":                33.5,
+		"33.5 South":           -33.5,
+	}
+	for c, expected := range tests {
+		if v, err := ParseCoordinate(c); err != nil {
+			t.Errorf("unexpected error for %v: %v", c, err)
+		} else if math.Abs(v-expected) > 1e-9 {
+			t.Errorf("expected %v got %v", expected, v)
+		}
+	}
+	if _, err := ParseCoordinate("north"); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestGetCoordinate(t *testing.T) {
+	obj := JSONObject{"lat": "18 deg 4' 12.00\"", "ref": "South", "num": 12.5}
+	if v, err := GetCoordinate("lat", "ref", obj); err != nil || math.Abs(v+18.07) > 1e-9 {
+		t.Errorf("expected -18.07 got %v %v", v, err)
+	}
+	if v, err := GetCoordinate("num", "ref", obj); err != nil || v != 12.5 {
+		t.Errorf("expected 12.5 got %v %v", v, err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	obj := JSONObject{
+		"num": 12.0, "numstr": " 12.5 ", "neg": -1.0, "yes": "Yes", "off": "Off", "one": []interface{}{"single"},
+		"two": []interface{}{"a", 1.0}, "bool": true, "str": "abc",
+	}
+	if v, err := Get[string]("num", obj); err != nil || v != "12" {
+		t.Errorf("expected 12 got %v %v", v, err)
+	}
+	if v, err := Get[float64]("numstr", obj); err != nil || v != 12.5 {
+		t.Errorf("expected 12.5 got %v %v", v, err)
+	}
+	if v, err := Get[int64]("numstr", obj); err != nil || v != 12 {
+		t.Errorf("expected 12 got %v %v", v, err)
+	}
+	if _, err := Get[uint]("neg", obj); err != IncorrectType {
+		t.Errorf("expected IncorrectType got %v", err)
+	}
+	if v, err := Get[bool]("yes", obj); err != nil || !v {
+		t.Errorf("expected true got %v %v", v, err)
+	}
+	if v, err := Get[bool]("off", obj); err != nil || v {
+		t.Errorf("expected false got %v %v", v, err)
+	}
+	if _, err := Get[bool]("str", obj); err != IncorrectType {
+		t.Errorf("expected IncorrectType got %v", err)
+	}
+	if v, err := Get[string]("one", obj); err != nil || v != "single" {
+		t.Errorf("expected single got %v %v", v, err)
+	}
+	if _, err := Get[string]("two", obj); err != IncorrectType {
+		t.Errorf("expected IncorrectType got %v", err)
+	}
+	if v, err := Get[string]("bool", obj); err != nil || v != "true" {
+		t.Errorf("expected true got %v %v", v, err)
+	}
+	if _, err := Get[float64]("missing", obj); err != ValueNotFound {
+		t.Errorf("expected ValueNotFound got %v", err)
+	}
+}
+
+func TestGetOr(t *testing.T) {
+	obj := JSONObject{"num": 3.0, "str": "abc"}
+	if v := GetOr("num", obj, 1); v != 3 {
+		t.Errorf("expected 3 got %v", v)
+	}
+	if v := GetOr("str", obj, 1.5); v != 1.5 {
+		t.Errorf("expected 1.5 got %v", v)
+	}
+	if v := GetOr("missing", obj, "def"); v != "def" {
+		t.Errorf("expected def got %v", v)
+	}
+}
+
+func TestGetStrings(t *testing.T) {
+	obj := JSONObject{"arr": []interface{}{"a", 2.0}, "str": "b"}
+	var s []string
+	if err := ScanStrings("arr", obj, &s); err != nil || len(s) != 2 || s[0] != "a" || s[1] != "2" {
+		t.Errorf("expected [a 2] got %v %v", s, err)
+	}
+	if s, err := GetStrings("str", obj); err != nil || len(s) != 1 || s[0] != "b" {
+		t.Errorf("expected [b] got %v %v", s, err)
+	}
+}
+
+func TestGetDuration(t *testing.T) {
+	tests := map[interface{}]time.Duration{
+		12.5:              12500 * time.Millisecond,
+		"12.50 s":         12500 * time.Millisecond,
+		"3.07 s (approx)": 3070 * time.Millisecond,
+		"0:01:23":         83 * time.Second,
+		"1:02:03":         time.Hour + 2*time.Minute + 3*time.Second,
+		"1m30s":           90 * time.Second,
+	}
+	for v, expected := range tests {
+		if d, err := GetDuration("d", JSONObject{"d": v}); err != nil || d != expected {
+			t.Errorf("expected %v for %v got %v %v", expected, v, d, err)
+		}
+	}
+	if _, err := GetDuration("d", JSONObject{"d": "long"}); err != IncorrectType {
+		t.Errorf("expected IncorrectType got %v", err)
+	}
+}
+
+func TestGetTime(t *testing.T) {
+	tests := map[string]string{
+		"2020:01:01 15:01:01":        "2020-01-01T15:01:01Z",
+		"2020:01:01 15:01:01+01:00":  "2020-01-01T15:01:01+01:00",
+		"2020:01:01 15:01:01.25Z":    "2020-01-01T15:01:01.25Z",
+		"2020:01:01 15:01:01 +01:00": "2020-01-01T15:01:01+01:00",
+		"2020-01-01T15:01:01-05:00":  "2020-01-01T15:01:01-05:00",
+		"2020:01:01":                 "2020-01-01T00:00:00Z",
+	}
+	for v, expected := range tests {
+		var dt time.Time
+		if err := ScanTime("dt", JSONObject{"dt": v}, &dt); err != nil || dt.Format(time.RFC3339Nano) != expected {
+			t.Errorf("expected %v for %v got %v %v", expected, v, dt, err)
+		}
+	}
+	if _, err := GetTime("missing", rootObj); err != ValueNotFound {
+		t.Errorf("expected ValueNotFound got %v", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	obj := JSONObject{
+		"Camera": map[string]interface{}{"Make": "Canon", "ISO": 100.0},
+		"Image":  map[string]interface{}{"LensModel": "EF 50mm"},
+		"Other":  map[string]interface{}{"Keywords": []interface{}{"a", "b"}, "Nested": []interface{}{[]interface{}{"c"}}},
+	}
+	tests := map[string]interface{}{
+		"Camera.Make":        "Canon",
+		"*.LensModel":        "EF 50mm",
+		"Other.Keywords[1]":  "b",
+		"Other.Nested[0][0]": "c",
+		"*.Keywords[0]":      "a",
+	}
+	for path, expected := range tests {
+		if v, err := Query(obj, path); err != nil || v != expected {
+			t.Errorf("expected %v for %v got %v %v", expected, path, v, err)
+		}
+	}
+	errs := map[string]error{
+		"Camera.Model":      ValueNotFound,
+		"*.Model":           ValueNotFound,
+		"Other.Keywords[2]": ValueNotFound,
+		"Camera.Make[0]":    IncorrectType,
+		"Camera.Make.X":     IncorrectType,
+		"Camera..Make":      InvalidPath,
+		"Other.Keywords[x]": InvalidPath,
+		"Other.Keywords[0":  InvalidPath,
+		"":                  InvalidPath,
+	}
+	for path, expected := range errs {
+		if _, err := Query(obj, path); err != expected {
+			t.Errorf("expected %v for %v got %v", expected, path, err)
+		}
+	}
+	if v, err := QueryAs[uint](obj, "Camera.ISO"); err != nil || v != 100 {
+		t.Errorf("expected 100 got %v %v", v, err)
+	}
+}
+
+func TestParseRational(t *testing.T) {
+	tests := map[string]float64{"1/250": 0.004, "+1/3": 1.0 / 3, "-2/3": -2.0 / 3, "0.5": 0.5, " 30 ": 30}
+	for s, expected := range tests {
+		if v, err := ParseRational(s); err != nil || math.Abs(v-expected) > 1e-9 {
+			t.Errorf("expected %v for %v got %v %v", expected, s, v, err)
+		}
+	}
+	for _, s := range []string{"1/0", "a/b", "", "1/"} {
+		if _, err := ParseRational(s); err != IncorrectType {
+			t.Errorf("expected IncorrectType for %v got %v", s, err)
+		}
+	}
+}
+
+func TestParseUnit(t *testing.T) {
+	tests := []struct {
+		s    string
+		v    float64
+		unit string
+	}{
+		{"35.0 mm", 35, "mm"},
+		{"35.0 mm (35 mm equivalent: 52.5 mm)", 35, "mm"},
+		{"90 deg", 90, "deg"},
+		{"1/250 s", 0.004, "s"},
+		{"100 m Above Sea Level", 100, "m"},
+		{"12.5", 12.5, ""},
+	}
+	for _, test := range tests {
+		if v, unit, err := ParseUnit(test.s); err != nil || v != test.v || unit != test.unit {
+			t.Errorf("expected %v %v for %v got %v %v %v", test.v, test.unit, test.s, v, unit, err)
+		}
+	}
+	if _, _, err := ParseUnit("mm"); err != IncorrectType {
+		t.Errorf("expected IncorrectType got %v", err)
+	}
+}
+
+func TestGetUnit(t *testing.T) {
+	obj := JSONObject{"focal": "35.0 mm", "num": 12.0, "alt": "1.5 km", "exp": "1/250", "dir": "90 deg"}
+	if v, err := GetUnit("focal", "mm", obj); err != nil || v != 35 {
+		t.Errorf("expected 35 got %v %v", v, err)
+	}
+	if v, err := GetUnit("num", "mm", obj); err != nil || v != 12 {
+		t.Errorf("expected 12 got %v %v", v, err)
+	}
+	if v, err := GetUnit("alt", "m", obj); err != nil || v != 1500 {
+		t.Errorf("expected 1500 got %v %v", v, err)
+	}
+	if _, err := GetUnit("dir", "m", obj); err != IncorrectType {
+		t.Errorf("expected IncorrectType got %v", err)
+	}
+	if _, err := GetUnit("missing", "m", obj); err != ValueNotFound {
+		t.Errorf("expected ValueNotFound got %v", err)
+	}
+	var v float64
+	if err := ScanRational("exp", obj, &v); err != nil || v != 0.004 {
+		t.Errorf("expected 0.004 got %v %v", v, err)
+	}
+	if err := ScanRational("num", obj, &v); err != nil || v != 12 {
+		t.Errorf("expected 12 got %v %v", v, err)
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		s         string
+		expected  string
+		precision Precision
+		zone      bool
+	}{
+		{"2020:01:01 15:01:01", "2020-01-01T15:01:01Z", PrecisionSecond, false},
+		{"2020:01:01 15:01:01.25+01:00", "2020-01-01T15:01:01.25+01:00", PrecisionSubSecond, true},
+		{"2020:01:01 15:01:01Z", "2020-01-01T15:01:01Z", PrecisionSecond, true},
+		{"2020:01:01 15:01:01 -0500", "2020-01-01T15:01:01-05:00", PrecisionSecond, true},
+		{"2020-01-01T15:01+02:00", "2020-01-01T15:01:00+02:00", PrecisionMinute, true},
+		{"2020-01-01T15:01:01.123456789123Z", "2020-01-01T15:01:01.123456789Z", PrecisionSubSecond, true},
+		{"2020:01:01", "2020-01-01T00:00:00Z", PrecisionDay, false},
+		{"20200102", "2020-01-02T00:00:00Z", PrecisionDay, false},
+		{"2020:05:00", "2020-05-01T00:00:00Z", PrecisionMonth, false},
+		{"2020:00:00 00:00:00", "2020-01-01T00:00:00Z", PrecisionYear, false},
+		{"2020", "2020-01-01T00:00:00Z", PrecisionYear, false},
+	}
+	for _, test := range tests {
+		ts, err := ParseTimestamp(test.s)
+		if err != nil {
+			t.Errorf("unexpected error for %v: %v", test.s, err)
+		} else if v := ts.Time.Format(time.RFC3339Nano); v != test.expected || ts.Precision != test.precision ||
+			ts.ZoneKnown != test.zone {
+			t.Errorf("expected %v %v %v for %v got %v %v %v", test.expected, test.precision, test.zone, test.s,
+				v, ts.Precision, ts.ZoneKnown)
+		}
+	}
+	for _, s := range []string{"0000:00:00 00:00:00", "    :  :     :  :  ", ""} {
+		if _, err := ParseTimestamp(s); err != ZeroDate {
+			t.Errorf("expected ZeroDate for %q got %v", s, err)
+		}
+	}
+	for _, s := range []string{"2020/01/01 15:01:01", "2020:02:30", "2020:01:01 25:00:00", "2020:01:01 15:01:01+25:00"} {
+		if _, err := ParseTimestamp(s); err != IncorrectType {
+			t.Errorf("expected IncorrectType for %q got %v", s, err)
+		}
+	}
+}
+
+func TestParseExifDateTime(t *testing.T) {
+	ts, err := ParseExifDateTime("2020:01:01 15:01:01", "025", "+01:00")
+	if err != nil || ts.Time.Format(time.RFC3339Nano) != "2020-01-01T15:01:01.025+01:00" ||
+		ts.Precision != PrecisionSubSecond || !ts.ZoneKnown {
+		t.Errorf("unexpected timestamp %+v %v", ts, err)
+	}
+	//values in the date take precedence
+	ts, err = ParseExifDateTime("2020:01:01 15:01:01.5Z", "025", "+01:00")
+	if err != nil || ts.Time.Format(time.RFC3339Nano) != "2020-01-01T15:01:01.5Z" {
+		t.Errorf("unexpected timestamp %+v %v", ts, err)
+	}
+	if _, err := ParseExifDateTime("2020:01:01 15:01:01", "abc", ""); err != IncorrectType {
+		t.Errorf("expected IncorrectType got %v", err)
+	}
+	if _, err := ParseDateTime("2020:01:01 15:01:01", "+1"); err != IncorrectType {
+		t.Errorf("expected IncorrectType got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package json
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var InvalidPath = errors.New("Invalid Path")
+
+// pathStep is one dot separated part of a path, a key followed by zero or more array indexes
+type pathStep struct {
+	key     string
+	indexes []int
+}
+
+// Query returns the value at path. A path is a list of keys separated by dots, e.g.
+// Camera.Make, where each key can be followed by array indexes, e.g. Other.Keywords[0].
+// The key * matches any key and the first match in key order is returned, e.g. *.LensModel
+func Query(obj JSONObject, path string) (interface{}, error) {
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return query(obj, steps)
+}
+
+// QueryAs is like Query but converts the value to T in the same way as Get
+func QueryAs[T Value](obj JSONObject, path string) (T, error) {
+	v, err := Query(obj, path)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return Convert[T](v)
+}
+
+func parsePath(path string) ([]pathStep, error) {
+	if path == "" {
+		return nil, InvalidPath
+	}
+	parts := strings.Split(path, ".")
+	steps := make([]pathStep, 0, len(parts))
+	for _, p := range parts {
+		i := strings.IndexByte(p, '[')
+		if i < 0 {
+			i = len(p)
+		}
+		step := pathStep{key: p[:i]}
+		if step.key == "" {
+			return nil, InvalidPath
+		}
+		for rest := p[i:]; rest != ""; {
+			end := strings.IndexByte(rest, ']')
+			if rest[0] != '[' || end < 0 {
+				return nil, InvalidPath
+			}
+			n, err := strconv.Atoi(rest[1:end])
+			if err != nil || n < 0 {
+				return nil, InvalidPath
+			}
+			step.indexes = append(step.indexes, n)
+			rest = rest[end+1:]
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func query(v interface{}, steps []pathStep) (interface{}, error) {
+	if len(steps) == 0 {
+		return v, nil
+	}
+	var obj map[string]interface{}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		obj = t
+	case JSONObject:
+		obj = t
+	default:
+		return nil, IncorrectType
+	}
+	step := steps[0]
+	if step.key != "*" {
+		child, found := obj[step.key]
+		if !found {
+			return nil, ValueNotFound
+		}
+		return queryStep(child, step, steps[1:])
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if ret, err := queryStep(obj[k], step, steps[1:]); err == nil {
+			return ret, nil
+		}
+	}
+	return nil, ValueNotFound
+}
+
+func queryStep(v interface{}, step pathStep, rest []pathStep) (interface{}, error) {
+	for _, i := range step.indexes {
+		var arr []interface{}
+		switch t := v.(type) {
+		case []interface{}:
+			arr = t
+		case JSONArray:
+			arr = t
+		default:
+			return nil, IncorrectType
+		}
+		if i >= len(arr) {
+			return nil, ValueNotFound
+		}
+		v = arr[i]
+	}
+	return query(v, rest)
+}
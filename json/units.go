@@ -0,0 +1,113 @@
+package json
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// lengthUnits are the length units GetUnit converts between, in meters
+var lengthUnits = map[string]float64{
+	"mm": 0.001,
+	"cm": 0.01,
+	"m":  1,
+	"km": 1000,
+}
+
+// ParseRational parses a decimal number or a fraction as exiftool prints EXIF rationals,
+// e.g. 1/250, +1/3 or 0.5
+func ParseRational(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	num, den, fraction := strings.Cut(s, "/")
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil {
+		return 0, IncorrectType
+	}
+	if !fraction {
+		return n, nil
+	}
+	d, err := strconv.ParseFloat(strings.TrimSpace(den), 64)
+	if err != nil || d == 0 {
+		return 0, IncorrectType
+	}
+	return n / d, nil
+}
+
+// ParseUnit parses a rational followed by an optional unit, e.g. 35.0 mm, 90 deg or 1/250 s.
+// Anything after the unit, e.g. the Above Sea Level of an altitude, is ignored
+func ParseUnit(s string) (float64, string, error) {
+	s = strings.TrimSpace(s)
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsLetter(r) || r == '('
+	})
+	if end < 0 {
+		end = len(s)
+	}
+	v, err := ParseRational(s[:end])
+	if err != nil {
+		return 0, "", err
+	}
+	unit := s[end:]
+	if i := strings.IndexFunc(unit, func(r rune) bool { return !unicode.IsLetter(r) }); i >= 0 {
+		unit = unit[:i]
+	}
+	return v, unit, nil
+}
+
+// GetRational returns field as a number. Strings are parsed with ParseRational
+func GetRational(field string, obj JSONObject) (float64, error) {
+	v, err := Get[float64](field, obj)
+	if err != IncorrectType {
+		return v, err
+	}
+	s, err := GetString(field, obj)
+	if err != nil {
+		return 0, err
+	}
+	return ParseRational(s)
+}
+
+func ScanRational(field string, obj JSONObject, val *float64) error {
+	v, err := GetRational(field, obj)
+	if err != nil {
+		return err
+	}
+	*val = v
+	return nil
+}
+
+// GetUnit returns field in unit. Numbers and strings without a unit are assumed to be in unit
+// and lengths are converted between mm, cm, m and km. IncorrectType is returned for any other
+// unit
+func GetUnit(field string, unit string, obj JSONObject) (float64, error) {
+	v, err := Get[float64](field, obj)
+	if err != IncorrectType {
+		return v, err
+	}
+	s, err := GetString(field, obj)
+	if err != nil {
+		return 0, err
+	}
+	v, u, err := ParseUnit(s)
+	switch {
+	case err != nil:
+		return 0, err
+	case u == "" || u == unit:
+		return v, nil
+	}
+	from, ok1 := lengthUnits[u]
+	to, ok2 := lengthUnits[unit]
+	if !ok1 || !ok2 {
+		return 0, IncorrectType
+	}
+	return v * from / to, nil
+}
+
+func ScanUnit(field string, unit string, obj JSONObject, val *float64) error {
+	v, err := GetUnit(field, unit, obj)
+	if err != nil {
+		return err
+	}
+	*val = v
+	return nil
+}
@@ -2,6 +2,8 @@ package mexif
 
 import (
 	"github.com/msvens/mexif/json"
+	"sort"
+	"strings"
 )
 
 const Audio = "Audio"
@@ -14,9 +16,9 @@ const Image = "Image"
 const Location = "Location"
 const Other = "Other"
 const Preview = "Preview"
-const Printing = "Printinng"
+const Printing = "Printing"
 const Time = "Time"
-const Unknown = "Unknow"
+const Unknown = "Unknown"
 const Video = "Video"
 
 type ExifData struct {
@@ -34,6 +36,8 @@ type ExifData struct {
 	Time     json.JSONObject `json:"time,omitempty"`
 	Unknown  json.JSONObject `json:"unknown,omitempty"`
 	Video    json.JSONObject `json:"video,omitempty"`
+
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 func NewExifData(root json.JSONObject) *ExifData {
@@ -53,5 +57,107 @@ func NewExifData(root json.JSONObject) *ExifData {
 	_ = json.ScanObject(Time, root, &ret.Time)
 	_ = json.ScanObject(Unknown, root, &ret.Unknown)
 	_ = json.ScanObject(Video, root, &ret.Video)
+
+	//exiftool reports minor problems as Warning tags in the ExifTool group
+	for k, v := range ret.ExifTool {
+		if k != "Warning" && !strings.HasPrefix(k, "Warning ") {
+			continue
+		}
+		if s, ok := v.(string); ok {
+			ret.Warnings = append(ret.Warnings, s)
+		} else if arr, ok := v.([]interface{}); ok {
+			for _, w := range arr {
+				if s, ok := w.(string); ok {
+					ret.Warnings = append(ret.Warnings, s)
+				}
+			}
+		}
+	}
+	sort.Strings(ret.Warnings)
 	return &ret
 }
+
+// Groups are the exiftool -g2 groups in the order Find searches them
+var Groups = []string{Image, Camera, Time, Location, Author, Document, Audio, Video, Device, Printing,
+	Preview, Other, ExifTool, Unknown}
+
+// Group returns the tags of the named group or nil if there is no such group
+func (ed *ExifData) Group(name string) json.JSONObject {
+	switch name {
+	case Audio:
+		return ed.Audio
+	case Author:
+		return ed.Author
+	case Camera:
+		return ed.Camera
+	case Device:
+		return ed.Device
+	case Document:
+		return ed.Document
+	case ExifTool:
+		return ed.ExifTool
+	case Image:
+		return ed.Image
+	case Location:
+		return ed.Location
+	case Other:
+		return ed.Other
+	case Preview:
+		return ed.Preview
+	case Printing:
+		return ed.Printing
+	case Time:
+		return ed.Time
+	case Unknown:
+		return ed.Unknown
+	case Video:
+		return ed.Video
+	}
+	return nil
+}
+
+// Object returns the groups as a single object keyed by group name, the same layout as
+// exiftool -g2 -json
+func (ed *ExifData) Object() json.JSONObject {
+	ret := json.JSONObject{}
+	for _, g := range Groups {
+		if tags := ed.Group(g); len(tags) > 0 {
+			ret[g] = map[string]interface{}(tags)
+		}
+	}
+	return ret
+}
+
+// Query returns the value at a dotted path, e.g. Camera.Make, *.LensModel or
+// Other.Keywords[0]. See json.Query. Unlike json.Query a leading * searches the groups in
+// Groups order, so *.LensModel returns the same value as Find("LensModel")
+func (ed *ExifData) Query(path string) (interface{}, error) {
+	return ed.query(ed.Object(), path)
+}
+
+// query runs path on root, the result of Object
+func (ed *ExifData) query(root json.JSONObject, path string) (interface{}, error) {
+	if !strings.HasPrefix(path, "*.") && !strings.HasPrefix(path, "*[") && path != "*" {
+		return json.Query(root, path)
+	}
+	for _, g := range Groups {
+		if _, found := root[g]; !found {
+			continue
+		}
+		v, err := json.Query(root, g+path[1:])
+		if err == nil || err == json.InvalidPath {
+			return v, err
+		}
+	}
+	return nil, json.ValueNotFound
+}
+
+// Find searches the groups for tag and returns its value and the group it was found in
+func (ed *ExifData) Find(tag string) (interface{}, string, bool) {
+	for _, g := range Groups {
+		if v, found := ed.Group(g)[tag]; found {
+			return v, g, true
+		}
+	}
+	return nil, "", false
+}
@@ -0,0 +1,53 @@
+package mexif
+
+import (
+	"github.com/msvens/mexif/json"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	roots := testRoots(t, `[{"Camera":{"Make":"Canon"},"Image":{"LensModel":"EF 50mm"},"Printing":{"Copies":1},
+		"Other":{"Keywords":["a","b"]}}]`)
+	data := NewExifData(roots[0])
+	if v, group, found := data.Find("LensModel"); !found || v != "EF 50mm" || group != Image {
+		t.Errorf("expected EF 50mm in Image got %v %v %v", v, group, found)
+	}
+	if _, _, found := data.Find("Model"); found {
+		t.Errorf("expected Model to be missing")
+	}
+	tests := map[string]interface{}{
+		"Camera.Make":       "Canon",
+		"*.LensModel":       "EF 50mm",
+		"Other.Keywords[1]": "b",
+		"Printing.Copies":   1.0,
+	}
+	for path, expected := range tests {
+		if v, err := data.Query(path); err != nil || v != expected {
+			t.Errorf("expected %v for %v got %v %v", expected, path, v, err)
+		}
+	}
+	if _, err := data.Query("*..LensModel"); err != json.InvalidPath {
+		t.Errorf("expected InvalidPath got %v", err)
+	}
+	if _, err := data.Query("*.Model"); err != json.ValueNotFound {
+		t.Errorf("expected ValueNotFound got %v", err)
+	}
+}
+
+func TestQueryGroupOrder(t *testing.T) {
+	//Camera sorts before Image but Image comes first in Groups
+	data := testExifData(t, `[{"Camera":{"LensModel":"camera"},"Image":{"LensModel":"image"}}]`)
+	v, group, _ := data.Find("LensModel")
+	if v != "image" || group != Image {
+		t.Errorf("expected image in Image got %v in %v", v, group)
+	}
+	if q, err := data.Query("*.LensModel"); err != nil || q != v {
+		t.Errorf("expected Query to return %v as Find got %v %v", v, q, err)
+	}
+	var lens struct {
+		Model string `exif:"*.LensModel"`
+	}
+	if err := Decode(data, &lens); err != nil || lens.Model != v {
+		t.Errorf("expected Decode to return %v got %v %v", v, lens.Model, err)
+	}
+}
@@ -0,0 +1,146 @@
+package native
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var errBadBox = errors.New("bad ISO base media box")
+
+// box is an ISO base media box. start and size are those of the payload after the header
+type box struct {
+	typ   string
+	start int64
+	size  int64
+}
+
+func (b box) end() int64 {
+	return b.start + b.size
+}
+
+// readBoxes reads the headers of the boxes between start and end
+func readBoxes(r io.ReaderAt, start, end int64) ([]box, error) {
+	var boxes []box
+	header := make([]byte, 16)
+	for pos := start; pos+8 <= end; {
+		if _, err := r.ReadAt(header[:8], pos); err != nil {
+			return boxes, err
+		}
+		size := int64(binary.BigEndian.Uint32(header))
+		b := box{typ: string(header[4:8]), start: pos + 8}
+		switch size {
+		case 0:
+			//the last box extends to the end
+			size = end - pos
+		case 1:
+			if pos+16 > end {
+				return boxes, errBadBox
+			}
+			if _, err := r.ReadAt(header[8:], pos+8); err != nil {
+				return boxes, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:]))
+			b.start += 8
+		}
+		if size < b.start-pos || size > end-pos {
+			return boxes, errBadBox
+		}
+		b.size = size - (b.start - pos)
+		boxes = append(boxes, b)
+		pos += size
+	}
+	return boxes, nil
+}
+
+// findBox returns the first box of type typ
+func findBox(boxes []box, typ string) (box, bool) {
+	for _, b := range boxes {
+		if b.typ == typ {
+			return b, true
+		}
+	}
+	return box{}, false
+}
+
+// readPayload reads the payload of a box. Boxes larger than limit are not read
+func readPayload(r io.ReaderAt, b box, limit int64) ([]byte, error) {
+	if b.size > limit {
+		return nil, errBadBox
+	}
+	data := make([]byte, b.size)
+	if _, err := r.ReadAt(data, b.start); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// boxReader reads the big-endian fields of a box payload. Reading past the end sets err
+// and returns zero values that must not be used
+type boxReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (br *boxReader) next(n int) []byte {
+	if br.err != nil || n < 0 || br.pos+n > len(br.data) {
+		br.err = errBadBox
+		//the zero value is only large enough for the fixed size fields
+		if n < 0 || n > 64 {
+			n = 64
+		}
+		return make([]byte, n)
+	}
+	b := br.data[br.pos : br.pos+n]
+	br.pos += n
+	return b
+}
+
+func (br *boxReader) uint8() uint8 {
+	return br.next(1)[0]
+}
+
+func (br *boxReader) uint16() uint16 {
+	return binary.BigEndian.Uint16(br.next(2))
+}
+
+func (br *boxReader) uint32() uint32 {
+	return binary.BigEndian.Uint32(br.next(4))
+}
+
+// uint reads an unsigned integer of 0, 2, 4 or 8 bytes
+func (br *boxReader) uint(size int) uint64 {
+	switch size {
+	case 0:
+		return 0
+	case 2:
+		return uint64(br.uint16())
+	case 4:
+		return uint64(br.uint32())
+	case 8:
+		return binary.BigEndian.Uint64(br.next(8))
+	}
+	br.err = errBadBox
+	return 0
+}
+
+// fullBox reads the version and flags of a full box
+func (br *boxReader) fullBox() (version uint8, flags uint32) {
+	v := br.uint32()
+	return uint8(v >> 24), v & 0xffffff
+}
+
+// string reads a NUL terminated string
+func (br *boxReader) string() string {
+	for i := br.pos; i < len(br.data); i++ {
+		if br.data[i] == 0 {
+			s := string(br.data[br.pos:i])
+			br.pos = i + 1
+			return s
+		}
+	}
+	s := string(br.data[br.pos:])
+	br.pos = len(br.data)
+	return s
+}
@@ -0,0 +1,128 @@
+package native
+
+import (
+	"fmt"
+	"github.com/msvens/mexif/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// addComposite adds the tags exiftool derives from other tags, e.g. ImageSize or the GPS
+// coordinates including the hemisphere
+func (m *metadata) addComposite() {
+	//the image size of videos is in the Video group
+	width, _ := m.find("ImageWidth")
+	height, _ := m.find("ImageHeight")
+	w, okW := width.(float64)
+	h, okH := height.(float64)
+	//as exiftool the cropped raw image size of RAF files replaces the size of the JPEG
+	if cropped, found := m.get(groupImage, "RawImageCroppedSize"); found {
+		if _, err := fmt.Sscanf(text(cropped), "%gx%g", &w, &h); err == nil {
+			okW, okH = true, true
+		}
+	}
+	if okW && okH {
+		m.set(groupImage, "ImageSize", fmt.Sprintf("%sx%s", formatNumber(w), formatNumber(h)))
+		mp := w * h / 1e6
+		precision := 1
+		if mp < 0.001 {
+			precision = 6
+		} else if mp < 1 {
+			precision = 3
+		}
+		m.set(groupImage, "Megapixels", value(fmt.Sprintf("%.*f", precision, mp)))
+	}
+
+	if f, ok := m.number(groupImage, "FNumber"); ok {
+		m.set(groupCamera, "Aperture", value(fmt.Sprintf("%.1f", f)))
+	} else if f, ok := m.number(groupImage, "ApertureValue"); ok {
+		m.set(groupCamera, "Aperture", value(fmt.Sprintf("%.1f", f)))
+	}
+	if v, ok := m.get(groupImage, "ExposureTime"); ok {
+		m.set(groupImage, "ShutterSpeed", v)
+	} else if v, ok := m.get(groupImage, "ShutterSpeedValue"); ok {
+		m.set(groupImage, "ShutterSpeed", v)
+	}
+
+	m.addSubSecDate("SubSecDateTimeOriginal", "DateTimeOriginal", "SubSecTimeOriginal", "OffsetTimeOriginal")
+	m.addSubSecDate("SubSecCreateDate", "CreateDate", "SubSecTimeDigitized", "OffsetTimeDigitized")
+	m.addSubSecDate("SubSecModifyDate", "ModifyDate", "SubSecTime", "OffsetTime")
+
+	lat, okLat := m.coordinate("GPSLatitude", "GPSLatitudeRef", "N", "S")
+	lon, okLon := m.coordinate("GPSLongitude", "GPSLongitudeRef", "E", "W")
+	if okLat && okLon {
+		m.set(groupLocation, "GPSPosition", lat+", "+lon)
+	}
+	if alt, ok := m.get(groupLocation, "GPSAltitude"); ok {
+		if a, err := strconv.ParseFloat(strings.TrimSuffix(text(alt), " m"), 64); err == nil {
+			ref, _ := m.get(groupLocation, "GPSAltitudeRef")
+			if ref != "Below Sea Level" {
+				ref = "Above Sea Level"
+			}
+			m.set(groupLocation, "GPSAltitude", fmt.Sprintf("%s m %s", formatNumber(math.Trunc(a*10)/10), ref))
+		}
+	}
+	date, okD := m.get(groupTime, "GPSDateStamp")
+	tm, okT := m.get(groupTime, "GPSTimeStamp")
+	if okD && okT {
+		m.set(groupTime, "GPSDateTime", text(date)+" "+text(tm)+"Z")
+	}
+}
+
+func (m *metadata) addSubSecDate(tag string, dateTag string, subSecTag string, offsetTag string) {
+	date, ok := m.get(groupTime, dateTag)
+	if !ok {
+		return
+	}
+	subSec, ok := m.get(groupTime, subSecTag)
+	if !ok {
+		return
+	}
+	s := text(date) + "." + strings.TrimSpace(text(subSec))
+	if offset, ok := m.get(groupTime, offsetTag); ok {
+		s += text(offset)
+	}
+	m.set(groupTime, tag, s)
+}
+
+// coordinate replaces a GPS coordinate with one that includes the hemisphere of the
+// reference tag and returns it
+func (m *metadata) coordinate(tag string, refTag string, positive string, negative string) (string, bool) {
+	v, ok := m.get(groupLocation, tag)
+	if !ok {
+		return "", false
+	}
+	c, err := json.ParseCoordinate(text(v))
+	if err != nil {
+		return "", false
+	}
+	ref, ok := m.get(groupLocation, refTag)
+	if !ok {
+		return text(v), true
+	}
+	hemisphere := positive
+	if r := text(ref); r != "" && strings.EqualFold(r[:1], negative) {
+		hemisphere = negative
+	}
+	s := dms(c) + " " + hemisphere
+	m.set(groupLocation, tag, s)
+	return s, true
+}
+
+func (m *metadata) number(group, tag string) (float64, bool) {
+	v, ok := m.get(group, tag)
+	if !ok {
+		return 0, false
+	}
+	f, ok := v.(float64)
+	return f, ok
+}
+
+// text returns a value as exiftool printed it
+func text(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return formatNumber(f)
+	}
+	return fmt.Sprint(v)
+}
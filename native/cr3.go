@@ -0,0 +1,110 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// canonUUID is the uuid box in the moov box of CR3 files with the metadata and thumbnail.
+// previewUUID is the top level uuid box with the preview
+var (
+	canonUUID   = []byte{0x85, 0xc0, 0xb6, 0x87, 0x82, 0x0f, 0x11, 0xe0, 0x81, 0x11, 0xf4, 0xce, 0x46, 0x2b, 0x6a, 0x48}
+	previewUUID = []byte{0xea, 0xf4, 0x2b, 0x5e, 0x1c, 0x98, 0x4b, 0x88, 0xb9, 0xfb, 0xb7, 0xdc, 0x40, 0x6e, 0x4d, 0x16}
+)
+
+func isCR3(header []byte) bool {
+	return len(header) >= 12 && string(header[4:8]) == "ftyp" && string(header[8:12]) == "crx "
+}
+
+// readCR3 reads a Canon CR3 file. The EXIF directories are stored as separate TIFF
+// structures in the CMT boxes of the Canon uuid box
+func readCR3(m *metadata, r io.ReaderAt, size int64) error {
+	boxes, err := readBoxes(r, 0, size)
+	if err != nil && len(boxes) == 0 {
+		return err
+	}
+	for _, b := range boxes {
+		switch b.typ {
+		case "moov":
+			children, _ := readBoxes(r, b.start, b.end())
+			for _, c := range children {
+				if c.typ == "uuid" && hasUUID(r, c, canonUUID) {
+					m.readCanonUUID(r, c)
+				}
+			}
+		case "uuid":
+			if !hasUUID(r, b, previewUUID) {
+				continue
+			}
+			//the uuid is followed by 8 unknown bytes
+			children, _ := readBoxes(r, b.start+24, b.end())
+			if prvw, found := findBox(children, "PRVW"); found {
+				if offset, length, ok := cr3JPEG(r, prvw, 12); ok {
+					m.previews = append(m.previews, preview{offset: offset, length: length})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func hasUUID(r io.ReaderAt, b box, uuid []byte) bool {
+	id := make([]byte, 16)
+	if b.size < 16 {
+		return false
+	}
+	_, err := r.ReadAt(id, b.start)
+	return err == nil && bytes.Equal(id, uuid)
+}
+
+func (m *metadata) readCanonUUID(r io.ReaderAt, b box) {
+	boxes, err := readBoxes(r, b.start+16, b.end())
+	if err != nil {
+		m.warn("Invalid Canon uuid box")
+	}
+	for _, c := range boxes {
+		switch c.typ {
+		case "CMT1":
+			m.readCMT(r, c, "IFD0", exifTags)
+		case "CMT2":
+			m.readCMT(r, c, "ExifIFD", exifTags)
+		case "CMT4":
+			m.readCMT(r, c, "GPS", gpsTags)
+		case "THMB":
+			if _, length, ok := cr3JPEG(r, c, 8); ok {
+				m.set(groupPreview, "ThumbnailImage", binaryData(length))
+			}
+		}
+	}
+}
+
+// readCMT reads a CMT box. Each box is a TIFF structure with a single directory
+func (m *metadata) readCMT(r io.ReaderAt, b box, name string, tags map[uint16]tagInfo) {
+	t, offset, err := newTIFF(r, b.start, b.size)
+	if err != nil {
+		m.warn("Invalid %s box", b.typ)
+		return
+	}
+	m.add(groupOther, "ExifByteOrder", t.byteOrder())
+	if _, err := m.readDir(t, offset, name, tags, false); err != nil {
+		m.warn("Bad %s directory", name)
+	}
+}
+
+// cr3JPEG returns the location of the JPEG image of a THMB or PRVW box. The JPEG follows a
+// 16 byte header with the JPEG length at lengthOffset
+func cr3JPEG(r io.ReaderAt, b box, lengthOffset int) (int64, int64, bool) {
+	header := make([]byte, 18)
+	if b.size < int64(len(header)) {
+		return 0, 0, false
+	}
+	if _, err := r.ReadAt(header, b.start); err != nil {
+		return 0, 0, false
+	}
+	length := int64(binary.BigEndian.Uint32(header[lengthOffset:]))
+	if length > b.size-16 || !bytes.Equal(header[16:], []byte{0xff, 0xd8}) {
+		return 0, 0, false
+	}
+	return b.start + 16, length, true
+}
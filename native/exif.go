@@ -0,0 +1,456 @@
+package native
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf16"
+)
+
+// tagInfo describes how a tag is named, grouped and printed. A nil print uses the default
+// conversion of the entry. Tags with offset set are offsets that are reported relative to
+// the start of the file
+type tagInfo struct {
+	name   string
+	group  string
+	print  func(e *entry) interface{}
+	offset bool
+}
+
+var exifTags = map[uint16]tagInfo{
+	0x00fe: {name: "SubfileType", group: groupImage, print: enum(map[int]string{
+		0: "Full-resolution image", 1: "Reduced-resolution image", 2: "Single page of multi-page image",
+		3: "Single page of multi-page reduced-resolution image", 4: "Transparency mask",
+	})},
+	0x0100: {name: "ImageWidth", group: groupImage},
+	0x0101: {name: "ImageHeight", group: groupImage},
+	0x0102: {name: "BitsPerSample", group: groupImage},
+	0x0103: {name: "Compression", group: groupImage, print: enum(map[int]string{
+		1: "Uncompressed", 2: "CCITT 1D", 3: "T4/Group 3 Fax", 4: "T6/Group 4 Fax", 5: "LZW",
+		6: "JPEG (old-style)", 7: "JPEG", 8: "Adobe Deflate", 32773: "PackBits", 34892: "Lossy JPEG",
+	})},
+	0x0106: {name: "PhotometricInterpretation", group: groupImage, print: enum(map[int]string{
+		0: "WhiteIsZero", 1: "BlackIsZero", 2: "RGB", 3: "RGB Palette", 4: "Transparency Mask",
+		5: "CMYK", 6: "YCbCr", 8: "CIELab", 32803: "Color Filter Array", 34892: "Linear Raw",
+	})},
+	0x010e: {name: "ImageDescription", group: groupImage},
+	0x010f: {name: "Make", group: groupCamera},
+	0x0110: {name: "Model", group: groupCamera},
+	0x0112: {name: "Orientation", group: groupImage, print: enum(map[int]string{
+		1: "Horizontal (normal)", 2: "Mirror horizontal", 3: "Rotate 180", 4: "Mirror vertical",
+		5: "Mirror horizontal and rotate 270 CW", 6: "Rotate 90 CW",
+		7: "Mirror horizontal and rotate 90 CW", 8: "Rotate 270 CW",
+	})},
+	0x0115: {name: "SamplesPerPixel", group: groupImage},
+	0x0116: {name: "RowsPerStrip", group: groupImage},
+	0x011a: {name: "XResolution", group: groupImage},
+	0x011b: {name: "YResolution", group: groupImage},
+	0x011c: {name: "PlanarConfiguration", group: groupImage, print: enum(map[int]string{1: "Chunky", 2: "Planar"})},
+	0x0128: {name: "ResolutionUnit", group: groupImage, print: enum(map[int]string{1: "None", 2: "inches", 3: "cm"})},
+	0x0131: {name: "Software", group: groupImage},
+	0x0132: {name: "ModifyDate", group: groupTime},
+	0x013b: {name: "Artist", group: groupAuthor},
+	0x013c: {name: "HostComputer", group: groupImage},
+	0x013e: {name: "WhitePoint", group: groupImage},
+	0x013f: {name: "PrimaryChromaticities", group: groupImage},
+	0x0201: {name: "ThumbnailOffset", group: groupImage, offset: true},
+	0x0202: {name: "ThumbnailLength", group: groupImage},
+	0x0211: {name: "YCbCrCoefficients", group: groupImage},
+	0x0213: {name: "YCbCrPositioning", group: groupImage, print: enum(map[int]string{1: "Centered", 2: "Co-sited"})},
+	0x0214: {name: "ReferenceBlackWhite", group: groupImage},
+	0x4746: {name: "Rating", group: groupImage},
+	0x4749: {name: "RatingPercent", group: groupImage},
+	0x8298: {name: "Copyright", group: groupAuthor},
+	0x829a: {name: "ExposureTime", group: groupImage, print: func(e *entry) interface{} {
+		return value(exposureTime(e.number(0)))
+	}},
+	0x829d: {name: "FNumber", group: groupImage, print: func(e *entry) interface{} {
+		return value(fNumber(e.number(0)))
+	}},
+	0x8822: {name: "ExposureProgram", group: groupCamera, print: enum(map[int]string{
+		0: "Not Defined", 1: "Manual", 2: "Program AE", 3: "Aperture-priority AE",
+		4: "Shutter speed priority AE", 5: "Creative (Slow speed)", 6: "Action (High speed)",
+		7: "Portrait", 8: "Landscape", 9: "Bulb",
+	})},
+	0x8824: {name: "SpectralSensitivity", group: groupCamera},
+	0x8827: {name: "ISO", group: groupImage},
+	0x8830: {name: "SensitivityType", group: groupImage, print: enum(map[int]string{
+		0: "Unknown", 1: "Standard Output Sensitivity", 2: "Recommended Exposure Index", 3: "ISO Speed",
+		4: "Standard Output Sensitivity and Recommended Exposure Index",
+		5: "Standard Output Sensitivity and ISO Speed", 6: "Recommended Exposure Index and ISO Speed",
+		7: "Standard Output Sensitivity, Recommended Exposure Index and ISO Speed",
+	})},
+	0x8832: {name: "RecommendedExposureIndex", group: groupImage},
+	0x9000: {name: "ExifVersion", group: groupImage, print: undefString},
+	0x9003: {name: "DateTimeOriginal", group: groupTime},
+	0x9004: {name: "CreateDate", group: groupTime},
+	0x9010: {name: "OffsetTime", group: groupTime},
+	0x9011: {name: "OffsetTimeOriginal", group: groupTime},
+	0x9012: {name: "OffsetTimeDigitized", group: groupTime},
+	0x9101: {name: "ComponentsConfiguration", group: groupImage, print: componentsConfiguration},
+	0x9102: {name: "CompressedBitsPerPixel", group: groupImage},
+	0x9201: {name: "ShutterSpeedValue", group: groupImage, print: func(e *entry) interface{} {
+		v := e.number(0)
+		if math.Abs(v) >= 100 {
+			return value(exposureTime(0))
+		}
+		return value(exposureTime(math.Pow(2, -v)))
+	}},
+	0x9202: {name: "ApertureValue", group: groupImage, print: apexAperture},
+	0x9203: {name: "BrightnessValue", group: groupImage},
+	0x9204: {name: "ExposureCompensation", group: groupImage, print: func(e *entry) interface{} {
+		return value(fraction(e.number(0)))
+	}},
+	0x9205: {name: "MaxApertureValue", group: groupCamera, print: apexAperture},
+	0x9206: {name: "SubjectDistance", group: groupCamera, print: func(e *entry) interface{} {
+		return withUnit(e, "m")
+	}},
+	0x9207: {name: "MeteringMode", group: groupCamera, print: enum(map[int]string{
+		0: "Unknown", 1: "Average", 2: "Center-weighted average", 3: "Spot", 4: "Multi-spot",
+		5: "Multi-segment", 6: "Partial", 255: "Other",
+	})},
+	0x9208: {name: "LightSource", group: groupCamera, print: enum(lightSources)},
+	0x9209: {name: "Flash", group: groupCamera, print: enum(flashModes)},
+	0x920a: {name: "FocalLength", group: groupCamera, print: func(e *entry) interface{} {
+		return fmt.Sprintf("%.1f mm", e.number(0))
+	}},
+	0x9214: {name: "SubjectArea", group: groupCamera},
+	0x9286: {name: "UserComment", group: groupImage, print: encodedString},
+	0x9290: {name: "SubSecTime", group: groupTime},
+	0x9291: {name: "SubSecTimeOriginal", group: groupTime},
+	0x9292: {name: "SubSecTimeDigitized", group: groupTime},
+	0x9c9b: {name: "XPTitle", group: groupImage, print: xpString},
+	0x9c9c: {name: "XPComment", group: groupImage, print: xpString},
+	0x9c9d: {name: "XPAuthor", group: groupAuthor, print: xpString},
+	0x9c9e: {name: "XPKeywords", group: groupImage, print: xpString},
+	0x9c9f: {name: "XPSubject", group: groupImage, print: xpString},
+	0xa000: {name: "FlashpixVersion", group: groupImage, print: undefString},
+	0xa001: {name: "ColorSpace", group: groupImage, print: enum(map[int]string{
+		1: "sRGB", 2: "Adobe RGB", 0xfffd: "Wide Gamut RGB", 0xfffe: "ICC Profile", 0xffff: "Uncalibrated",
+	})},
+	0xa002: {name: "ExifImageWidth", group: groupImage},
+	0xa003: {name: "ExifImageHeight", group: groupImage},
+	0xa20e: {name: "FocalPlaneXResolution", group: groupCamera},
+	0xa20f: {name: "FocalPlaneYResolution", group: groupCamera},
+	0xa210: {name: "FocalPlaneResolutionUnit", group: groupCamera, print: enum(map[int]string{
+		1: "None", 2: "inches", 3: "cm", 4: "mm", 5: "um",
+	})},
+	0xa215: {name: "ExposureIndex", group: groupImage},
+	0xa217: {name: "SensingMethod", group: groupCamera, print: enum(map[int]string{
+		1: "Not defined", 2: "One-chip color area", 3: "Two-chip color area", 4: "Three-chip color area",
+		5: "Color sequential area", 7: "Trilinear", 8: "Color sequential linear",
+	})},
+	0xa300: {name: "FileSource", group: groupImage, print: enum(map[int]string{
+		1: "Film Scanner", 2: "Reflection Print Scanner", 3: "Digital Camera",
+	})},
+	0xa301: {name: "SceneType", group: groupImage, print: enum(map[int]string{1: "Directly photographed"})},
+	0xa401: {name: "CustomRendered", group: groupImage, print: enum(map[int]string{0: "Normal", 1: "Custom"})},
+	0xa402: {name: "ExposureMode", group: groupImage, print: enum(map[int]string{0: "Auto", 1: "Manual", 2: "Auto bracket"})},
+	0xa403: {name: "WhiteBalance", group: groupCamera, print: enum(map[int]string{0: "Auto", 1: "Manual"})},
+	0xa404: {name: "DigitalZoomRatio", group: groupImage},
+	0xa405: {name: "FocalLengthIn35mmFormat", group: groupCamera, print: func(e *entry) interface{} {
+		return withUnit(e, "mm")
+	}},
+	0xa406: {name: "SceneCaptureType", group: groupImage, print: enum(map[int]string{
+		0: "Standard", 1: "Landscape", 2: "Portrait", 3: "Night", 4: "Other",
+	})},
+	0xa407: {name: "GainControl", group: groupImage, print: enum(map[int]string{
+		0: "None", 1: "Low gain up", 2: "High gain up", 3: "Low gain down", 4: "High gain down",
+	})},
+	0xa408: {name: "Contrast", group: groupCamera, print: enum(map[int]string{0: "Normal", 1: "Low", 2: "High"})},
+	0xa409: {name: "Saturation", group: groupCamera, print: enum(map[int]string{0: "Normal", 1: "Low", 2: "High"})},
+	0xa40a: {name: "Sharpness", group: groupCamera, print: enum(map[int]string{0: "Normal", 1: "Soft", 2: "Hard"})},
+	0xa40c: {name: "SubjectDistanceRange", group: groupCamera, print: enum(map[int]string{
+		0: "Unknown", 1: "Macro", 2: "Close", 3: "Distant",
+	})},
+	0xa420: {name: "ImageUniqueID", group: groupImage},
+	0xa430: {name: "OwnerName", group: groupAuthor},
+	0xa431: {name: "SerialNumber", group: groupCamera},
+	0xa432: {name: "LensInfo", group: groupImage, print: lensInfo},
+	0xa433: {name: "LensMake", group: groupImage},
+	0xa434: {name: "LensModel", group: groupImage},
+	0xa435: {name: "LensSerialNumber", group: groupImage},
+	0xa500: {name: "Gamma", group: groupImage},
+	0xc612: {name: "DNGVersion", group: groupImage, print: func(e *entry) interface{} {
+		return strings.ReplaceAll(e.formatted(), " ", ".")
+	}},
+	0xc613: {name: "DNGBackwardVersion", group: groupImage, print: func(e *entry) interface{} {
+		return strings.ReplaceAll(e.formatted(), " ", ".")
+	}},
+	0xc614: {name: "UniqueCameraModel", group: groupCamera},
+}
+
+var gpsTags = map[uint16]tagInfo{
+	0x00: {name: "GPSVersionID", group: groupLocation, print: func(e *entry) interface{} {
+		return strings.ReplaceAll(e.formatted(), " ", ".")
+	}},
+	0x01: {name: "GPSLatitudeRef", group: groupLocation, print: letters(map[string]string{"N": "North", "S": "South"})},
+	0x02: {name: "GPSLatitude", group: groupLocation, print: gpsCoordinate},
+	0x03: {name: "GPSLongitudeRef", group: groupLocation, print: letters(map[string]string{"E": "East", "W": "West"})},
+	0x04: {name: "GPSLongitude", group: groupLocation, print: gpsCoordinate},
+	0x05: {name: "GPSAltitudeRef", group: groupLocation, print: enum(map[int]string{0: "Above Sea Level", 1: "Below Sea Level"})},
+	0x06: {name: "GPSAltitude", group: groupLocation, print: func(e *entry) interface{} {
+		return withUnit(e, "m")
+	}},
+	0x07: {name: "GPSTimeStamp", group: groupTime, print: gpsTimeStamp},
+	0x08: {name: "GPSSatellites", group: groupLocation},
+	0x09: {name: "GPSStatus", group: groupLocation, print: letters(map[string]string{
+		"A": "Measurement Active", "V": "Measurement Void",
+	})},
+	0x0a: {name: "GPSMeasureMode", group: groupLocation, print: letters(map[string]string{
+		"2": "2-Dimensional Measurement", "3": "3-Dimensional Measurement",
+	})},
+	0x0b: {name: "GPSDOP", group: groupLocation},
+	0x0c: {name: "GPSSpeedRef", group: groupLocation, print: letters(map[string]string{"K": "km/h", "M": "mph", "N": "knots"})},
+	0x0d: {name: "GPSSpeed", group: groupLocation},
+	0x0e: {name: "GPSTrackRef", group: groupLocation, print: letters(directionRefs)},
+	0x0f: {name: "GPSTrack", group: groupLocation},
+	0x10: {name: "GPSImgDirectionRef", group: groupLocation, print: letters(directionRefs)},
+	0x11: {name: "GPSImgDirection", group: groupLocation},
+	0x12: {name: "GPSMapDatum", group: groupLocation},
+	0x13: {name: "GPSDestLatitudeRef", group: groupLocation, print: letters(map[string]string{"N": "North", "S": "South"})},
+	0x14: {name: "GPSDestLatitude", group: groupLocation, print: gpsCoordinate},
+	0x15: {name: "GPSDestLongitudeRef", group: groupLocation, print: letters(map[string]string{"E": "East", "W": "West"})},
+	0x16: {name: "GPSDestLongitude", group: groupLocation, print: gpsCoordinate},
+	0x17: {name: "GPSDestBearingRef", group: groupLocation, print: letters(directionRefs)},
+	0x18: {name: "GPSDestBearing", group: groupLocation},
+	0x19: {name: "GPSDestDistanceRef", group: groupLocation, print: letters(map[string]string{
+		"K": "Kilometers", "M": "Miles", "N": "Nautical Miles",
+	})},
+	0x1a: {name: "GPSDestDistance", group: groupLocation},
+	0x1b: {name: "GPSProcessingMethod", group: groupLocation, print: encodedString},
+	0x1c: {name: "GPSAreaInformation", group: groupLocation, print: encodedString},
+	0x1d: {name: "GPSDateStamp", group: groupTime},
+	0x1e: {name: "GPSDifferential", group: groupLocation, print: enum(map[int]string{
+		0: "No Correction", 1: "Differential Corrected",
+	})},
+	0x1f: {name: "GPSHPositioningError", group: groupLocation, print: func(e *entry) interface{} {
+		return withUnit(e, "m")
+	}},
+}
+
+var interopTags = map[uint16]tagInfo{
+	0x0001: {name: "InteropIndex", group: groupImage, print: letters(map[string]string{
+		"R03": "R03 - DCF option file (Adobe RGB)", "R98": "R98 - DCF basic file (sRGB)",
+		"THM": "THM - DCF thumbnail file",
+	})},
+	0x0002: {name: "InteropVersion", group: groupImage, print: undefString},
+	0x1000: {name: "RelatedImageFileFormat", group: groupImage},
+	0x1001: {name: "RelatedImageWidth", group: groupImage},
+	0x1002: {name: "RelatedImageHeight", group: groupImage},
+}
+
+var directionRefs = map[string]string{"M": "Magnetic North", "T": "True North"}
+
+var lightSources = map[int]string{
+	0: "Unknown", 1: "Daylight", 2: "Fluorescent", 3: "Tungsten (Incandescent)", 4: "Flash",
+	9: "Fine Weather", 10: "Cloudy", 11: "Shade", 12: "Daylight Fluorescent",
+	13: "Day White Fluorescent", 14: "Cool White Fluorescent", 15: "White Fluorescent",
+	16: "Warm White Fluorescent", 17: "Standard Light A", 18: "Standard Light B",
+	19: "Standard Light C", 20: "D55", 21: "D65", 22: "D75", 23: "D50", 24: "ISO Studio Tungsten",
+	255: "Other",
+}
+
+var flashModes = map[int]string{
+	0x00: "No Flash", 0x01: "Fired", 0x05: "Fired, Return not detected",
+	0x07: "Fired, Return detected", 0x08: "On, Did not fire", 0x09: "On, Fired",
+	0x0d: "On, Return not detected", 0x0f: "On, Return detected", 0x10: "Off, Did not fire",
+	0x14: "Off, Did not fire, Return not detected", 0x18: "Auto, Did not fire",
+	0x19: "Auto, Fired", 0x1d: "Auto, Fired, Return not detected",
+	0x1f: "Auto, Fired, Return detected", 0x20: "No flash function",
+	0x30: "Off, No flash function", 0x41: "Fired, Red-eye reduction",
+	0x45: "Fired, Red-eye reduction, Return not detected",
+	0x47: "Fired, Red-eye reduction, Return detected", 0x49: "On, Red-eye reduction",
+	0x4d: "On, Red-eye reduction, Return not detected",
+	0x4f: "On, Red-eye reduction, Return detected", 0x50: "Off, Red-eye reduction",
+	0x58: "Auto, Did not fire, Red-eye reduction", 0x59: "Auto, Fired, Red-eye reduction",
+	0x5d: "Auto, Fired, Red-eye reduction, Return not detected",
+	0x5f: "Auto, Fired, Red-eye reduction, Return detected",
+}
+
+// enum prints the name of the first value of an entry
+func enum(names map[int]string) func(e *entry) interface{} {
+	return func(e *entry) interface{} {
+		v := e.number(0)
+		if name, found := names[int(v)]; found && !math.IsNaN(v) {
+			return name
+		}
+		return fmt.Sprintf("Unknown (%s)", e.formatted())
+	}
+}
+
+// letters prints the name of a string entry
+func letters(names map[string]string) func(e *entry) interface{} {
+	return func(e *entry) interface{} {
+		s := strings.TrimSpace(e.str())
+		if name, found := names[s]; found {
+			return name
+		}
+		return fmt.Sprintf("Unknown (%s)", s)
+	}
+}
+
+func withUnit(e *entry, unit string) interface{} {
+	s := e.formatted()
+	if s == "inf" || s == "undef" {
+		return s
+	}
+	return s + " " + unit
+}
+
+func undefString(e *entry) interface{} {
+	return value(e.str())
+}
+
+func apexAperture(e *entry) interface{} {
+	return value(fmt.Sprintf("%.1f", math.Pow(2, e.number(0)/2)))
+}
+
+func exposureTime(secs float64) string {
+	if secs > 0 && secs < 0.25001 {
+		return fmt.Sprintf("1/%d", int(0.5+1/secs))
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", secs), ".0")
+}
+
+func fNumber(f float64) string {
+	if f < 1 {
+		return fmt.Sprintf("%.2f", f)
+	}
+	return fmt.Sprintf("%.1f", f)
+}
+
+// fraction prints exposure compensation in thirds or halves, e.g. +1/3
+func fraction(v float64) string {
+	v *= 1.00001
+	switch {
+	case math.IsNaN(v):
+		return "undef"
+	case v == 0:
+		return "0"
+	case float64(int(v))/v > 0.999:
+		return fmt.Sprintf("%+d", int(v))
+	case float64(int(v*2))/(v*2) > 0.999:
+		return fmt.Sprintf("%+d/2", int(v*2))
+	case float64(int(v*3))/(v*3) > 0.999:
+		return fmt.Sprintf("%+d/3", int(v*3))
+	}
+	return fmt.Sprintf("%+.3g", v)
+}
+
+// lensInfo prints the focal length and aperture range of a lens, e.g. 18-55mm f/3.5-5.6
+func lensInfo(e *entry) interface{} {
+	if e.len() != 4 {
+		return e.value()
+	}
+	v := make([]string, 4)
+	for i := range v {
+		v[i] = e.formatValue(i)
+		if v[i] == "inf" || v[i] == "undef" {
+			v[i] = "?"
+		}
+	}
+	s := v[0]
+	if v[1] != "0" && v[1] != v[0] {
+		s += "-" + v[1]
+	}
+	s += "mm f/" + v[2]
+	if v[3] != "0" && v[3] != v[2] {
+		s += "-" + v[3]
+	}
+	return s
+}
+
+func componentsConfiguration(e *entry) interface{} {
+	names := []string{"-", "Y", "Cb", "Cr", "R", "G", "B"}
+	var ret []string
+	for _, b := range e.data {
+		if int(b) < len(names) {
+			ret = append(ret, names[b])
+		} else {
+			ret = append(ret, "Err")
+		}
+	}
+	return strings.Join(ret, ", ")
+}
+
+// dms prints a coordinate as degrees, minutes and seconds
+func dms(c float64) string {
+	c = math.Abs(c)
+	d := math.Floor(c)
+	m := math.Floor((c - d) * 60)
+	s := ((c-d)*60 - m) * 60
+	//avoid printing 60.00 seconds after rounding
+	if s >= 59.995 {
+		s = 0
+		m++
+		if m == 60 {
+			m = 0
+			d++
+		}
+	}
+	return fmt.Sprintf("%d deg %d' %.2f\"", int(d), int(m), s)
+}
+
+func gpsCoordinate(e *entry) interface{} {
+	c := 0.0
+	for i, v := range e.numbers() {
+		if i < 3 && !math.IsNaN(v) {
+			c += v / math.Pow(60, float64(i))
+		}
+	}
+	return dms(c)
+}
+
+func gpsTimeStamp(e *entry) interface{} {
+	if e.len() != 3 {
+		return e.value()
+	}
+	secs := (e.number(0)*60+e.number(1))*60 + e.number(2)
+	if math.IsNaN(secs) {
+		return e.formatted()
+	}
+	h := int(secs / 3600)
+	secs -= float64(h * 3600)
+	m := int(secs / 60)
+	secs -= float64(m * 60)
+	s := strings.TrimRight(strings.TrimRight(fmt.Sprintf("%09.6f", secs), "0"), ".")
+	return fmt.Sprintf("%02d:%02d:%s", h, m, s)
+}
+
+// encodedString decodes a string that starts with an 8 byte character code, e.g. UserComment
+func encodedString(e *entry) interface{} {
+	if len(e.data) < 8 {
+		return strings.TrimSpace(e.str())
+	}
+	code, data := string(e.data[:8]), e.data[8:]
+	var s string
+	switch {
+	case strings.HasPrefix(code, "UNICODE"):
+		s = decodeUTF16(data, e.order)
+	default:
+		s = string(data)
+		if i := strings.IndexByte(s, 0); i >= 0 {
+			s = s[:i]
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// xpString decodes the UCS-2 little endian strings Windows writes to the XP tags
+func xpString(e *entry) interface{} {
+	return decodeUTF16(e.data, binary.LittleEndian)
+}
+
+func decodeUTF16(b []byte, order binary.ByteOrder) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		c := order.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,350 @@
+package native
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// heifBrands are the major brands of HEIF files by file type
+var heifBrands = map[string]string{
+	"heic": "HEIC", "heix": "HEIC", "heim": "HEIC", "heis": "HEIC", "hevc": "HEIC", "hevx": "HEIC",
+	"mif1": "HEIF", "msf1": "HEIF",
+	"avif": "AVIF", "avis": "AVIF",
+}
+
+// heifRotations print the anticlockwise rotation of the irot property as an EXIF orientation
+var heifRotations = map[byte]string{
+	0: "Horizontal (normal)", 1: "Rotate 270 CW", 2: "Rotate 180", 3: "Rotate 90 CW",
+}
+
+// isHEIF returns a function that matches files with an ftyp box with a major brand of fileType
+func isHEIF(fileType string) func(header []byte) bool {
+	return func(header []byte) bool {
+		return len(header) >= 12 && string(header[4:8]) == "ftyp" && heifBrands[string(header[8:12])] == fileType
+	}
+}
+
+type heifExtent struct {
+	offset int64
+	length int64
+}
+
+// heifItem is an item of the meta box, e.g. an image, the Exif block or an XMP packet
+type heifItem struct {
+	id          uint32
+	typ         string
+	contentType string
+	//method is the construction method, 0 for file offsets and 1 for offsets in the idat box
+	method     uint16
+	extents    []heifExtent
+	properties []uint16
+}
+
+// heif holds the items and properties of the meta box
+type heif struct {
+	r          io.ReaderAt
+	size       int64
+	idat       []byte
+	primary    uint32
+	items      map[uint32]*heifItem
+	order      []uint32
+	properties []box
+	//propertyData is the payload of the ipco box that properties refer to
+	propertyData []byte
+}
+
+// readHEIF reads the items of the meta box. The Exif and XMP items are read as in other
+// formats and the primary image properties give the image size and rotation
+func readHEIF(m *metadata, r io.ReaderAt, size int64) error {
+	boxes, err := readBoxes(r, 0, size)
+	if err != nil && len(boxes) == 0 {
+		return err
+	}
+	meta, found := findBox(boxes, "meta")
+	if !found {
+		m.warn("No meta box found")
+		return nil
+	}
+	data, err := readPayload(r, meta, maxValueSize*16)
+	if err != nil {
+		m.warn("Invalid meta box")
+		return nil
+	}
+	h, err := parseHEIF(r, size, data)
+	if err != nil {
+		m.warn("Invalid meta box")
+		return nil
+	}
+
+	for _, id := range h.order {
+		item := h.items[id]
+		switch {
+		case item.typ == "Exif":
+			m.readHEIFExif(h, item)
+		case item.typ == "mime" && item.contentType == "application/rdf+xml":
+			data, err := h.itemData(item)
+			if err == nil {
+				err = m.readXMP(data)
+			}
+			if err != nil {
+				m.warn("Invalid XMP: %v", err)
+			}
+		}
+	}
+	if primary, found := h.items[h.primary]; found {
+		m.readHEIFProperties(h, primary)
+	}
+	return nil
+}
+
+// readHEIFExif reads the Exif item. It starts with the offset of the TIFF header
+func (m *metadata) readHEIFExif(h *heif, item *heifItem) {
+	data, err := h.itemData(item)
+	if err != nil || len(data) < 4 {
+		m.warn("Invalid Exif item")
+		return
+	}
+	br := boxReader{data: data}
+	offset := int64(br.uint32()) + 4
+	if offset >= int64(len(data)) {
+		m.warn("Invalid Exif item")
+		return
+	}
+	//read from the file when possible so that offsets are file offsets
+	r, base := io.ReaderAt(bytes.NewReader(data)), int64(0)
+	if item.method == 0 && len(item.extents) == 1 {
+		r, base = h.r, item.extents[0].offset
+	}
+	if err := m.readEXIF(r, base+offset, int64(len(data))-offset); err != nil {
+		m.warn("Malformed Exif item")
+	}
+}
+
+func (m *metadata) readHEIFProperties(h *heif, item *heifItem) {
+	for _, index := range item.properties {
+		if index == 0 || int(index) > len(h.properties) {
+			continue
+		}
+		p := h.properties[index-1]
+		br := boxReader{data: h.propertyData[p.start:p.end()]}
+		switch p.typ {
+		case "ispe":
+			br.fullBox()
+			w, ht := br.uint32(), br.uint32()
+			if br.err == nil {
+				m.set(groupImage, "ImageWidth", float64(w))
+				m.set(groupImage, "ImageHeight", float64(ht))
+				m.set(groupImage, "ImageSpatialExtent", fmt.Sprintf("%dx%d", w, ht))
+			}
+		case "irot":
+			if r := br.uint8(); br.err == nil {
+				m.set(groupImage, "Rotation", heifRotations[r&0x03])
+			}
+		}
+	}
+}
+
+// parseHEIF parses the payload of the meta box
+func parseHEIF(r io.ReaderAt, size int64, data []byte) (*heif, error) {
+	h := heif{r: r, size: size, items: map[uint32]*heifItem{}}
+	boxes, err := readBoxes(bytes.NewReader(data), 4, int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	payload := func(b box) *boxReader {
+		return &boxReader{data: data[b.start:b.end()]}
+	}
+	item := func(id uint32) *heifItem {
+		if _, found := h.items[id]; !found {
+			h.items[id] = &heifItem{id: id}
+			h.order = append(h.order, id)
+		}
+		return h.items[id]
+	}
+
+	for _, b := range boxes {
+		br := payload(b)
+		switch b.typ {
+		case "pitm":
+			if v, _ := br.fullBox(); v == 0 {
+				h.primary = uint32(br.uint16())
+			} else {
+				h.primary = br.uint32()
+			}
+		case "idat":
+			h.idat = br.data
+		case "iinf":
+			err = parseItemInfo(br, item)
+		case "iloc":
+			err = parseItemLocation(br, item)
+		case "iprp":
+			err = h.parseItemProperties(br.data, item)
+		}
+		if err == nil {
+			err = br.err
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &h, nil
+}
+
+func parseItemInfo(br *boxReader, item func(uint32) *heifItem) error {
+	var count uint64
+	if v, _ := br.fullBox(); v == 0 {
+		count = uint64(br.uint16())
+	} else {
+		count = uint64(br.uint32())
+	}
+	boxes, err := readBoxes(bytes.NewReader(br.data), int64(br.pos), int64(len(br.data)))
+	if err != nil {
+		return err
+	}
+	for i, b := range boxes {
+		if uint64(i) >= count {
+			break
+		}
+		if b.typ != "infe" {
+			continue
+		}
+		ir := &boxReader{data: br.data[b.start:b.end()]}
+		v, _ := ir.fullBox()
+		if v < 2 {
+			//older versions have no item types
+			continue
+		}
+		var id uint32
+		if v == 2 {
+			id = uint32(ir.uint16())
+		} else {
+			id = ir.uint32()
+		}
+		ir.uint16()
+		it := item(id)
+		it.typ = string(ir.next(4))
+		ir.string()
+		if it.typ == "mime" {
+			it.contentType = ir.string()
+		}
+		if ir.err != nil {
+			return ir.err
+		}
+	}
+	return nil
+}
+
+func parseItemLocation(br *boxReader, item func(uint32) *heifItem) error {
+	v, _ := br.fullBox()
+	sizes := br.uint16()
+	offsetSize, lengthSize := int(sizes>>12), int(sizes>>8&0x0f)
+	baseOffsetSize, indexSize := int(sizes>>4&0x0f), 0
+	if v == 1 || v == 2 {
+		indexSize = int(sizes & 0x0f)
+	}
+	var count uint64
+	if v < 2 {
+		count = uint64(br.uint16())
+	} else {
+		count = uint64(br.uint32())
+	}
+	for i := uint64(0); i < count && br.err == nil; i++ {
+		var id uint32
+		if v < 2 {
+			id = uint32(br.uint16())
+		} else {
+			id = br.uint32()
+		}
+		it := item(id)
+		if v == 1 || v == 2 {
+			it.method = br.uint16() & 0x0f
+		}
+		br.uint16()
+		base := int64(br.uint(baseOffsetSize))
+		extents := int(br.uint16())
+		it.extents = nil
+		for j := 0; j < extents && br.err == nil; j++ {
+			br.uint(indexSize)
+			offset := base + int64(br.uint(offsetSize))
+			length := int64(br.uint(lengthSize))
+			it.extents = append(it.extents, heifExtent{offset, length})
+		}
+	}
+	return br.err
+}
+
+// parseItemProperties reads the properties of the ipco box and their associations with
+// items. Property indexes start at 1
+func (h *heif) parseItemProperties(data []byte, item func(uint32) *heifItem) error {
+	boxes, err := readBoxes(bytes.NewReader(data), 0, int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, b := range boxes {
+		switch b.typ {
+		case "ipco":
+			h.propertyData = data[b.start:b.end()]
+			if h.properties, err = readBoxes(bytes.NewReader(h.propertyData), 0, b.size); err != nil {
+				return err
+			}
+		case "ipma":
+			br := &boxReader{data: data[b.start:b.end()]}
+			v, flags := br.fullBox()
+			count := br.uint32()
+			for i := uint32(0); i < count && br.err == nil; i++ {
+				var id uint32
+				if v < 1 {
+					id = uint32(br.uint16())
+				} else {
+					id = br.uint32()
+				}
+				it := item(id)
+				associations := int(br.uint8())
+				for j := 0; j < associations && br.err == nil; j++ {
+					//the high bit marks essential properties
+					if flags&1 != 0 {
+						it.properties = append(it.properties, br.uint16()&0x7fff)
+					} else {
+						it.properties = append(it.properties, uint16(br.uint8()&0x7f))
+					}
+				}
+			}
+			if br.err != nil {
+				return br.err
+			}
+		}
+	}
+	return nil
+}
+
+// itemData reads the extents of an item
+func (h *heif) itemData(item *heifItem) ([]byte, error) {
+	var data []byte
+	for _, e := range item.extents {
+		var src io.ReaderAt
+		size := h.size
+		switch item.method {
+		case 0:
+			src = h.r
+		case 1:
+			src, size = bytes.NewReader(h.idat), int64(len(h.idat))
+		default:
+			return nil, fmt.Errorf("unsupported construction method %d", item.method)
+		}
+		length := e.length
+		if length == 0 {
+			//the extent is the rest of the data
+			length = size - e.offset
+		}
+		if e.offset < 0 || length < 0 || e.offset+length > size || int64(len(data))+length > maxValueSize*16 {
+			return nil, errBadBox
+		}
+		b := make([]byte, length)
+		if _, err := src.ReadAt(b, e.offset); err != nil {
+			return nil, err
+		}
+		data = append(data, b...)
+	}
+	return data, nil
+}
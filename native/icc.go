@@ -0,0 +1,43 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// readICCDescription adds the description of an ICC profile as ProfileDescription. The
+// description is a textDescriptionType in version 2 profiles and a
+// multiLocalizedUnicodeType in version 4 profiles
+func (m *metadata) readICCDescription(data []byte) {
+	if len(data) < 132 {
+		return
+	}
+	count := int(binary.BigEndian.Uint32(data[128:]))
+	for i := 0; i < count && 132+12*i+12 <= len(data); i++ {
+		entry := data[132+12*i:]
+		if string(entry[:4]) != "desc" {
+			continue
+		}
+		offset, size := int(binary.BigEndian.Uint32(entry[4:])), int(binary.BigEndian.Uint32(entry[8:]))
+		if size < 12 || offset+size > len(data) {
+			return
+		}
+		tag := data[offset : offset+size]
+		switch string(tag[:4]) {
+		case "desc":
+			n := int(binary.BigEndian.Uint32(tag[8:]))
+			if n > 0 && 12+n <= len(tag) {
+				m.set(groupImage, "ProfileDescription", latin1(bytes.TrimRight(tag[12:12+n], "\x00")))
+			}
+		case "mluc":
+			if len(tag) < 28 {
+				return
+			}
+			n, pos := int(binary.BigEndian.Uint32(tag[20:])), int(binary.BigEndian.Uint32(tag[24:]))
+			if pos+n <= len(tag) {
+				m.set(groupImage, "ProfileDescription", decodeUTF16(tag[pos:pos+n], binary.BigEndian))
+			}
+		}
+		return
+	}
+}
@@ -0,0 +1,268 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf8"
+)
+
+const photoshopHeader = "Photoshop 3.0\x00"
+
+// resourceIPTC is the Photoshop image resource holding the IPTC-IIM datasets
+const resourceIPTC = 0x0404
+
+// utf8Charset is the ISO 2022 escape sequence of the 1:90 CodedCharacterSet dataset for UTF-8
+const utf8Charset = "\x1b%G"
+
+// iptcTag describes an IPTC-IIM dataset
+type iptcTag struct {
+	name  string
+	group string
+	//list datasets may be repeated and are returned as a list
+	list  bool
+	print func(s string) string
+}
+
+// iptcEnvelope are the datasets of record 1
+var iptcEnvelope = map[byte]iptcTag{
+	0:  {name: "EnvelopeRecordVersion", group: groupOther},
+	5:  {name: "Destination", group: groupOther, list: true},
+	20: {name: "FileFormat", group: groupOther},
+	30: {name: "ServiceIdentifier", group: groupOther},
+	40: {name: "EnvelopeNumber", group: groupOther},
+	50: {name: "ProductID", group: groupOther, list: true},
+	60: {name: "EnvelopePriority", group: groupOther},
+	70: {name: "DateSent", group: groupTime, print: iptcDate},
+	80: {name: "TimeSent", group: groupTime, print: iptcTime},
+	90: {name: "CodedCharacterSet", group: groupOther, print: codedCharacterSet},
+}
+
+// iptcApplication are the datasets of record 2
+var iptcApplication = map[byte]iptcTag{
+	0:   {name: "ApplicationRecordVersion", group: groupOther},
+	3:   {name: "ObjectTypeReference", group: groupOther},
+	4:   {name: "ObjectAttributeReference", group: groupOther, list: true},
+	5:   {name: "ObjectName", group: groupOther},
+	7:   {name: "EditStatus", group: groupOther},
+	10:  {name: "Urgency", group: groupOther},
+	12:  {name: "SubjectReference", group: groupOther, list: true},
+	15:  {name: "Category", group: groupOther},
+	20:  {name: "SupplementalCategories", group: groupOther, list: true},
+	22:  {name: "FixtureIdentifier", group: groupOther},
+	25:  {name: "Keywords", group: groupOther, list: true},
+	26:  {name: "ContentLocationCode", group: groupLocation, list: true},
+	27:  {name: "ContentLocationName", group: groupLocation, list: true},
+	30:  {name: "ReleaseDate", group: groupTime, print: iptcDate},
+	35:  {name: "ReleaseTime", group: groupTime, print: iptcTime},
+	37:  {name: "ExpirationDate", group: groupTime, print: iptcDate},
+	38:  {name: "ExpirationTime", group: groupTime, print: iptcTime},
+	40:  {name: "SpecialInstructions", group: groupOther},
+	42:  {name: "ActionAdvised", group: groupOther},
+	45:  {name: "ReferenceService", group: groupOther, list: true},
+	47:  {name: "ReferenceDate", group: groupTime, list: true, print: iptcDate},
+	50:  {name: "ReferenceNumber", group: groupOther, list: true},
+	55:  {name: "DateCreated", group: groupTime, print: iptcDate},
+	60:  {name: "TimeCreated", group: groupTime, print: iptcTime},
+	62:  {name: "DigitalCreationDate", group: groupTime, print: iptcDate},
+	63:  {name: "DigitalCreationTime", group: groupTime, print: iptcTime},
+	65:  {name: "OriginatingProgram", group: groupOther},
+	70:  {name: "ProgramVersion", group: groupOther},
+	75:  {name: "ObjectCycle", group: groupOther, print: objectCycle},
+	80:  {name: "By-line", group: groupAuthor, list: true},
+	85:  {name: "By-lineTitle", group: groupAuthor, list: true},
+	90:  {name: "City", group: groupLocation},
+	92:  {name: "Sub-location", group: groupLocation},
+	95:  {name: "Province-State", group: groupLocation},
+	100: {name: "Country-PrimaryLocationCode", group: groupLocation},
+	101: {name: "Country-PrimaryLocationName", group: groupLocation},
+	103: {name: "OriginalTransmissionReference", group: groupOther},
+	105: {name: "Headline", group: groupOther},
+	110: {name: "Credit", group: groupAuthor},
+	115: {name: "Source", group: groupAuthor},
+	116: {name: "CopyrightNotice", group: groupAuthor},
+	118: {name: "Contact", group: groupAuthor, list: true},
+	120: {name: "Caption-Abstract", group: groupOther},
+	121: {name: "LocalCaption", group: groupOther},
+	122: {name: "Writer-Editor", group: groupAuthor, list: true},
+	130: {name: "ImageType", group: groupOther},
+	131: {name: "ImageOrientation", group: groupOther, print: imageOrientation},
+	135: {name: "LanguageIdentifier", group: groupOther},
+}
+
+// readPhotoshop reads the image resource blocks of the APP13 segments and the IPTC-IIM
+// datasets of the IPTC resource
+func (m *metadata) readPhotoshop(data []byte) {
+	for len(data) >= 12 {
+		switch string(data[:4]) {
+		case "8BIM", "PHUT", "AgHg", "DCSR":
+		default:
+			m.warn("Bad Photoshop IRB resource")
+			return
+		}
+		id := binary.BigEndian.Uint16(data[4:])
+		//the name is a padded pascal string
+		nameLen := int(data[6]) + 1
+		nameLen += nameLen & 1
+		if 6+nameLen+4 > len(data) {
+			m.warn("Truncated Photoshop IRB resource")
+			return
+		}
+		start := 6 + nameLen + 4
+		size := int(binary.BigEndian.Uint32(data[start-4:]))
+		if size > len(data)-start {
+			m.warn("Truncated Photoshop IRB resource")
+			return
+		}
+		if id == resourceIPTC {
+			m.readIPTC(data[start : start+size])
+		}
+		next := start + size + size&1
+		if next > len(data) {
+			return
+		}
+		data = data[next:]
+	}
+}
+
+// readIPTC reads the datasets of the envelope and application records. Strings are
+// ISO-8859-1 unless the CodedCharacterSet dataset specifies UTF-8
+func (m *metadata) readIPTC(data []byte) {
+	type dataset struct {
+		tag   iptcTag
+		value []byte
+	}
+	var datasets []dataset
+	utf8Encoded := false
+	for len(data) >= 5 && data[0] == 0x1c {
+		record, number := data[1], data[2]
+		size, pos := int(binary.BigEndian.Uint16(data[3:])), 5
+		if size&0x8000 != 0 {
+			//extended dataset, the size is stored in the following bytes
+			n := size & 0x7fff
+			if n > 4 || pos+n > len(data) {
+				m.warn("Invalid IPTC dataset size")
+				return
+			}
+			size = 0
+			for _, b := range data[pos : pos+n] {
+				size = size<<8 | int(b)
+			}
+			pos += n
+		}
+		if size > len(data)-pos {
+			m.warn("Truncated IPTC dataset %d:%02d", record, number)
+			return
+		}
+		value := data[pos : pos+size]
+		data = data[pos+size:]
+
+		var tag iptcTag
+		var found bool
+		switch record {
+		case 1:
+			tag, found = iptcEnvelope[number]
+			if number == 90 {
+				utf8Encoded = string(value) == utf8Charset
+			}
+		case 2:
+			tag, found = iptcApplication[number]
+		}
+		if found {
+			datasets = append(datasets, dataset{tag, value})
+		}
+	}
+
+	//repeated datasets are collected in the order of their first occurrence
+	var tags []iptcTag
+	values := map[string][]interface{}{}
+	for _, d := range datasets {
+		if _, found := values[d.tag.name]; !found {
+			tags = append(tags, d.tag)
+		}
+		values[d.tag.name] = append(values[d.tag.name], iptcValue(d.tag, d.value, utf8Encoded))
+	}
+	for _, tag := range tags {
+		v := values[tag.name]
+		if tag.list && len(v) > 1 {
+			m.add(tag.group, tag.name, v)
+		} else {
+			m.add(tag.group, tag.name, v[0])
+		}
+	}
+
+	//the composite dates use the IPTC values, XMP has tags with the same names
+	dates := [][3]string{
+		{"DateTimeCreated", "DateCreated", "TimeCreated"},
+		{"DigitalCreationDateTime", "DigitalCreationDate", "DigitalCreationTime"},
+	}
+	for _, d := range dates {
+		date, okD := values[d[1]]
+		time, okT := values[d[2]]
+		if okD && okT {
+			m.add(groupTime, d[0], text(date[0])+" "+text(time[0]))
+		}
+	}
+}
+
+func iptcValue(tag iptcTag, b []byte, utf8Encoded bool) interface{} {
+	if strings.HasSuffix(tag.name, "RecordVersion") && len(b) == 2 {
+		return float64(binary.BigEndian.Uint16(b))
+	}
+	if tag.print != nil {
+		return value(tag.print(string(b)))
+	}
+	return value(decodeIPTCString(b, utf8Encoded))
+}
+
+// decodeIPTCString decodes ISO-8859-1 or UTF-8. Invalid UTF-8 falls back to ISO-8859-1
+func decodeIPTCString(b []byte, utf8Encoded bool) string {
+	b = bytes.TrimRight(b, "\x00")
+	if utf8Encoded && utf8.Valid(b) {
+		return string(b)
+	}
+	return latin1(b)
+}
+
+// iptcDate prints CCYYMMDD as CCYY:MM:DD
+func iptcDate(s string) string {
+	if len(s) != 8 {
+		return s
+	}
+	return s[:4] + ":" + s[4:6] + ":" + s[6:]
+}
+
+// iptcTime prints HHMMSS±HHMM as HH:MM:SS±HH:MM
+func iptcTime(s string) string {
+	if len(s) < 6 {
+		return s
+	}
+	ret := s[:2] + ":" + s[2:4] + ":" + s[4:6]
+	if len(s) == 11 {
+		ret += s[6:9] + ":" + s[9:]
+	}
+	return ret
+}
+
+func codedCharacterSet(s string) string {
+	if s == utf8Charset {
+		return "UTF8"
+	}
+	return s
+}
+
+func objectCycle(s string) string {
+	cycles := map[string]string{"a": "Morning", "p": "Evening", "b": "Both Morning and Evening"}
+	if c, found := cycles[s]; found {
+		return c
+	}
+	return s
+}
+
+func imageOrientation(s string) string {
+	orientations := map[string]string{"P": "Portrait", "L": "Landscape", "S": "Square"}
+	if o, found := orientations[s]; found {
+		return o
+	}
+	return s
+}
@@ -0,0 +1,151 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+var encodingProcesses = map[byte]string{
+	0xc0: "Baseline DCT, Huffman coding",
+	0xc1: "Extended sequential DCT, Huffman coding",
+	0xc2: "Progressive DCT, Huffman coding",
+	0xc3: "Lossless, Huffman coding",
+	0xc5: "Sequential DCT, differential Huffman coding",
+	0xc6: "Progressive DCT, differential Huffman coding",
+	0xc7: "Lossless, Differential Huffman coding",
+	0xc9: "Extended sequential DCT, arithmetic coding",
+	0xca: "Progressive DCT, arithmetic coding",
+	0xcb: "Lossless, arithmetic coding",
+	0xcd: "Sequential DCT, differential arithmetic coding",
+	0xce: "Progressive DCT, differential arithmetic coding",
+	0xcf: "Lossless, differential arithmetic coding",
+}
+
+func readJPEG(m *metadata, r io.ReaderAt, size int64) error {
+	return m.readJPEGAt(r, 0, size)
+}
+
+// readJPEGAt reads the segments of the JPEG between offset and end before the image data.
+// Metadata is stored in the application segments, the image size in the start of frame
+// segment. Embedded JPEGs, e.g. in raw files, are read in place so offsets are file offsets
+func (m *metadata) readJPEGAt(r io.ReaderAt, offset, end int64) error {
+	pos := offset + 2
+	exif := false
+	//the Photoshop resources may be split over several APP13 segments
+	var photoshop []byte
+	header := make([]byte, 4)
+segments:
+	for pos+4 <= end {
+		if _, err := r.ReadAt(header, pos); err != nil {
+			return err
+		}
+		if header[0] != 0xff {
+			m.warn("JPEG format error")
+			break segments
+		}
+		marker := header[1]
+		switch {
+		case marker == 0xff:
+			//fill byte
+			pos++
+			continue
+		case marker == 0x01 || marker >= 0xd0 && marker <= 0xd8:
+			pos += 2
+			continue
+		case marker == 0xd9 || marker == 0xda:
+			break segments
+		}
+		segment := int64(binary.BigEndian.Uint16(header[2:]))
+		if segment < 2 || pos+2+segment > end {
+			m.warn("JPEG segment extends beyond end of file")
+			break segments
+		}
+		start, length := pos+4, segment-2
+		pos += 2 + segment
+
+		switch {
+		case marker == 0xe1:
+			data := make([]byte, length)
+			if _, err := r.ReadAt(data, start); err != nil {
+				return err
+			}
+			switch {
+			case bytes.HasPrefix(data, exifHeader) && !exif:
+				exif = true
+				if err := m.readEXIF(r, start+6, length-6); err != nil {
+					m.warn("Malformed APP1 EXIF segment")
+				}
+			case bytes.HasPrefix(data, []byte(xmpHeader)):
+				if err := m.readXMP(data[len(xmpHeader):]); err != nil {
+					m.warn("Invalid XMP: %v", err)
+				}
+			}
+		case marker == 0xed:
+			data := make([]byte, length)
+			if _, err := r.ReadAt(data, start); err != nil {
+				return err
+			}
+			if bytes.HasPrefix(data, []byte(photoshopHeader)) {
+				photoshop = append(photoshop, data[len(photoshopHeader):]...)
+			}
+		case marker == 0xe0 && length >= 14:
+			data := make([]byte, length)
+			if _, err := r.ReadAt(data, start); err != nil {
+				return err
+			}
+			m.readJFIF(data)
+		case encodingProcesses[marker] != "" && length >= 6:
+			data := make([]byte, length)
+			if _, err := r.ReadAt(data, start); err != nil {
+				return err
+			}
+			m.readSOF(marker, data)
+		}
+	}
+	if photoshop != nil {
+		m.readPhotoshop(photoshop)
+	}
+	return nil
+}
+
+// readJFIF reads the APP0 segment. Its resolution has a lower priority than the EXIF one
+func (m *metadata) readJFIF(data []byte) {
+	if !bytes.HasPrefix(data, []byte("JFIF\x00")) {
+		return
+	}
+	m.add(groupImage, "JFIFVersion", value(fmt.Sprintf("%d.%02d", data[5], data[6])))
+	units := map[byte]string{0: "None", 1: "inches", 2: "cm"}
+	if u, found := units[data[7]]; found {
+		m.add(groupImage, "ResolutionUnit", u)
+	}
+	m.add(groupImage, "XResolution", float64(binary.BigEndian.Uint16(data[8:])))
+	m.add(groupImage, "YResolution", float64(binary.BigEndian.Uint16(data[10:])))
+}
+
+func (m *metadata) readSOF(marker byte, data []byte) {
+	m.set(groupImage, "EncodingProcess", encodingProcesses[marker])
+	m.set(groupImage, "BitsPerSample", float64(data[0]))
+	m.set(groupImage, "ImageHeight", float64(binary.BigEndian.Uint16(data[1:])))
+	m.set(groupImage, "ImageWidth", float64(binary.BigEndian.Uint16(data[3:])))
+	components := int(data[5])
+	m.set(groupImage, "ColorComponents", float64(components))
+	if components == 3 && len(data) >= 6+3*components {
+		h, v := data[7]>>4, data[7]&0x0f
+		if s, found := subSampling[[2]byte{h, v}]; found {
+			m.set(groupImage, "YCbCrSubSampling", s)
+		}
+	}
+}
+
+var subSampling = map[[2]byte]string{
+	{1, 1}: "YCbCr4:4:4 (1 1)",
+	{1, 2}: "YCbCr4:4:0 (1 2)",
+	{2, 1}: "YCbCr4:2:2 (2 1)",
+	{2, 2}: "YCbCr4:2:0 (2 2)",
+	{4, 1}: "YCbCr4:1:1 (4 1)",
+	{4, 2}: "YCbCr4:1:0 (4 2)",
+}
@@ -0,0 +1,252 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const tagMakerNote = 0x927c
+
+// makerNote describes how the maker note IFD of a camera vendor is stored
+type makerNote struct {
+	//make is the prefix of the upper case Make tag of the cameras that use the maker note
+	make   string
+	header string
+	//ifd is the offset of the IFD from the start of the maker note
+	ifd int64
+	//tiffHeader is true for maker notes with a TIFF header at offset 10. Offsets are relative
+	//to the TIFF header
+	tiffHeader bool
+	//relative is true for maker notes with offsets relative to the start of the maker note
+	//and the offset of the IFD after the header
+	relative bool
+	//order is the byte order of maker notes that do not use the order of the EXIF data
+	order binary.ByteOrder
+	tags  map[uint16]tagInfo
+	//structs read the tags that hold several values or directories
+	structs map[uint16]func(m *metadata, t *tiff, e *entry)
+}
+
+var makerNotes = []makerNote{
+	{make: "FUJIFILM", header: "FUJIFILM", relative: true, order: binary.LittleEndian, tags: fujifilmTags},
+	{make: "NIKON", header: "Nikon\x00\x02", tiffHeader: true, tags: nikonTags,
+		structs: map[uint16]func(m *metadata, t *tiff, e *entry){
+			0x0011: (*metadata).readNikonPreview, 0x0088: (*metadata).addNikonAFInfo,
+		}},
+	{make: "LEICA", header: "LEICA\x00", ifd: 8, tags: leicaTags},
+}
+
+// readMakerNote reads the maker note of the camera vendors in makerNotes. The layout of maker
+// notes is not documented so maker notes that can not be read are ignored
+func (m *metadata) readMakerNote(t *tiff, e *entry) {
+	camera, _ := m.get(groupCamera, "Make")
+	size := e.count * typeSizes[e.typ]
+	header := make([]byte, 16)
+	n, _ := t.r.ReadAt(header, e.offset)
+	header = header[:n]
+	for _, mn := range makerNotes {
+		if !strings.HasPrefix(strings.ToUpper(text(camera)), mn.make) || !bytes.HasPrefix(header, []byte(mn.header)) {
+			continue
+		}
+		mt, offset := t, e.offset+mn.ifd
+		switch {
+		case mn.tiffHeader:
+			//the header is followed by a version and 2 reserved bytes
+			var err error
+			start := e.offset + 10
+			if mt, offset, err = newTIFF(t.r, start, size-(start-e.offset)); err != nil {
+				return
+			}
+			mt.base = t.base + start
+		case mn.relative:
+			if len(header) < len(mn.header)+4 {
+				return
+			}
+			mt = &tiff{r: io.NewSectionReader(t.r, e.offset, size), base: t.base + e.offset, order: mn.order,
+				visited: map[int64]bool{}}
+			offset = int64(mn.order.Uint32(header[len(mn.header):]))
+		}
+		entries, _, err := mt.readIFD(offset)
+		if err != nil {
+			return
+		}
+		for _, e := range entries {
+			if add, found := mn.structs[e.tag]; found && e.data != nil {
+				add(m, mt, e)
+			} else if info, found := mn.tags[e.tag]; found {
+				m.addEntry(mt, e, info, true)
+			}
+		}
+		return
+	}
+}
+
+var fujifilmTags = map[uint16]tagInfo{
+	0x0000: {name: "Version", group: groupCamera, print: undefString},
+	0x0010: {name: "InternalSerialNumber", group: groupCamera},
+	0x1000: {name: "Quality", group: groupCamera, print: trimmed},
+	0x1001: {name: "Sharpness", group: groupCamera, print: enum(map[int]string{
+		0x00: "-4 (softest)", 0x01: "-3 (very soft)", 0x02: "-2 (soft)", 0x03: "0 (normal)",
+		0x04: "+2 (hard)", 0x05: "+3 (very hard)", 0x06: "+4 (hardest)", 0x82: "-1 (medium soft)",
+		0x84: "+1 (medium hard)", 0x8000: "Film Simulation", 0xffff: "n/a",
+	})},
+	0x1002: {name: "WhiteBalance", group: groupCamera, print: enum(map[int]string{
+		0x000: "Auto", 0x001: "Auto (white priority)", 0x002: "Auto (ambiance priority)",
+		0x100: "Daylight", 0x200: "Cloudy", 0x300: "Daylight Fluorescent", 0x301: "Day White Fluorescent",
+		0x302: "White Fluorescent", 0x303: "Warm White Fluorescent", 0x304: "Living Room Warm White Fluorescent",
+		0x400: "Incandescent", 0x500: "Flash", 0x600: "Underwater", 0xf00: "Custom", 0xf01: "Custom2",
+		0xf02: "Custom3", 0xf03: "Custom4", 0xf04: "Custom5", 0xff0: "Kelvin",
+	})},
+	0x1003: {name: "Saturation", group: groupCamera, print: enum(map[int]string{
+		0x000: "0 (normal)", 0x080: "+1 (medium high)", 0x100: "+2 (high)", 0x0c0: "+3 (very high)",
+		0x0e0: "+4 (highest)", 0x180: "-1 (medium low)", 0x200: "Low", 0x300: "None (B&W)",
+		0x301: "B&W Red Filter", 0x302: "B&W Yellow Filter", 0x303: "B&W Green Filter", 0x310: "B&W Sepia",
+		0x400: "-2 (low)", 0x4c0: "-3 (very low)", 0x4e0: "-4 (lowest)", 0x500: "Acros",
+		0x501: "Acros Red Filter", 0x502: "Acros Yellow Filter", 0x503: "Acros Green Filter",
+		0x8000: "Film Simulation",
+	})},
+	0x1010: {name: "FujiFlashMode", group: groupCamera, print: enum(map[int]string{
+		0: "Auto", 1: "On", 2: "Off", 3: "Red-eye reduction", 4: "External", 16: "Commander",
+		0x8000: "Not Attached", 0x8120: "TTL", 0x8320: "TTL Auto - Did not fire", 0x9840: "Manual",
+		0x9860: "Flash Commander", 0x9880: "Multi-flash", 0xa920: "1st Curtain (front)",
+		0xaa20: "TTL Slow - 1st Curtain (front)", 0xab20: "TTL Auto - 1st Curtain (front)",
+		0xad20: "TTL - Red-eye Flash - 1st Curtain (front)", 0xc920: "2nd Curtain (rear)",
+	})},
+	0x1021: {name: "FocusMode", group: groupCamera, print: enum(map[int]string{0: "Auto", 1: "Manual", 65535: "Movie"})},
+	0x1023: {name: "FocusPixel", group: groupCamera},
+	0x1031: {name: "PictureMode", group: groupCamera, print: enum(map[int]string{
+		0x0: "Auto", 0x1: "Portrait", 0x2: "Landscape", 0x3: "Macro", 0x4: "Sports", 0x5: "Night Scene",
+		0x6: "Program AE", 0x7: "Natural Light", 0x8: "Anti-blur", 0x9: "Beach & Snow", 0xa: "Sunset",
+		0xb: "Museum", 0xc: "Party", 0xd: "Flower", 0xe: "Text", 0xf: "Natural Light & Flash",
+		0x100: "Aperture-priority AE", 0x200: "Shutter speed priority AE", 0x300: "Manual",
+	})},
+	0x1050: {name: "ShutterType", group: groupCamera, print: enum(map[int]string{
+		0: "Mechanical", 1: "Electronic", 2: "Electronic (long shutter speed)", 3: "Electronic Front Curtain",
+	})},
+	0x1300: {name: "BlurWarning", group: groupCamera, print: enum(map[int]string{0: "None", 1: "Blur Warning"})},
+	0x1301: {name: "FocusWarning", group: groupCamera, print: enum(map[int]string{0: "Good", 1: "Out of focus"})},
+	0x1302: {name: "ExposureWarning", group: groupCamera, print: enum(map[int]string{0: "Good", 1: "Bad exposure"})},
+	0x1400: {name: "DynamicRange", group: groupCamera, print: enum(map[int]string{1: "Standard", 3: "Wide"})},
+	0x1401: {name: "FilmMode", group: groupCamera, print: enum(map[int]string{
+		0x000: "F0/Standard (Provia)", 0x100: "F1/Studio Portrait", 0x110: "F1a/Studio Portrait Enhanced Saturation",
+		0x120: "F1b/Studio Portrait Smooth Skin Tone (Astia)", 0x130: "F1c/Studio Portrait Increased Sharpness",
+		0x200: "F2/Fujichrome (Velvia)", 0x300: "F3/Studio Portrait Ex", 0x400: "F4/Velvia",
+		0x500: "Pro Neg. Std", 0x501: "Pro Neg. Hi", 0x600: "Classic Chrome", 0x700: "Eterna",
+		0x800: "Classic Negative", 0x900: "Bleach Bypass", 0xa00: "Nostalgic Neg", 0xb00: "Reala ACE",
+	})},
+	0x1402: {name: "DynamicRangeSetting", group: groupCamera, print: enum(map[int]string{
+		0x0: "Auto", 0x1: "Manual", 0x100: "Standard (100%)", 0x200: "Wide1 (230%)", 0x201: "Wide2 (400%)",
+		0x8000: "Film Simulation",
+	})},
+	0x1404: {name: "MinFocalLength", group: groupCamera},
+	0x1405: {name: "MaxFocalLength", group: groupCamera},
+	0x1406: {name: "MaxApertureAtMinFocal", group: groupCamera},
+	0x1407: {name: "MaxApertureAtMaxFocal", group: groupCamera},
+	0x1438: {name: "ImageCount", group: groupCamera, print: func(e *entry) interface{} {
+		return float64(int(e.number(0)) & 0x7fff)
+	}},
+}
+
+var nikonTags = map[uint16]tagInfo{
+	0x0001: {name: "MakerNoteVersion", group: groupCamera, print: func(e *entry) interface{} {
+		s := e.str()
+		if len(s) == 4 {
+			s = strings.TrimPrefix(s[:2], "0") + "." + s[2:]
+		}
+		return value(s)
+	}},
+	0x0002: {name: "ISO", group: groupCamera, print: func(e *entry) interface{} {
+		if e.len() < 2 {
+			return e.value()
+		}
+		return e.number(1)
+	}},
+	0x0004: {name: "Quality", group: groupCamera, print: trimmed},
+	0x0005: {name: "WhiteBalance", group: groupCamera, print: trimmed},
+	0x0007: {name: "FocusMode", group: groupCamera, print: trimmed},
+	0x0008: {name: "FlashSetting", group: groupCamera, print: trimmed},
+	0x0009: {name: "FlashType", group: groupCamera, print: trimmed},
+	0x000b: {name: "WhiteBalanceFineTune", group: groupCamera},
+	0x0012: {name: "FlashExposureComp", group: groupCamera, print: nikonEV},
+	0x001d: {name: "SerialNumber", group: groupCamera, print: trimmed},
+	0x0022: {name: "ActiveD-Lighting", group: groupCamera, print: enum(map[int]string{
+		0: "Off", 1: "Low", 3: "Normal", 5: "High", 7: "Extra High", 8: "Extra High 1", 9: "Extra High 2",
+		10: "Extra High 3", 11: "Extra High 4", 0xffff: "Auto",
+	})},
+	0x0081: {name: "ToneComp", group: groupCamera, print: trimmed},
+	0x0084: {name: "Lens", group: groupCamera, print: lensInfo},
+	0x0090: {name: "LightSource", group: groupCamera, print: trimmed},
+	0x0095: {name: "NoiseReduction", group: groupCamera, print: trimmed},
+	0x00a7: {name: "ShutterCount", group: groupCamera},
+	0x00a9: {name: "ImageOptimization", group: groupCamera, print: trimmed},
+	0x00ab: {name: "VariProgram", group: groupCamera, print: trimmed},
+}
+
+// nikonAFAreaModes and nikonAFPoints print the AFInfo structure
+var nikonAFAreaModes = map[int]string{
+	0: "Single Area", 1: "Dynamic Area", 2: "Dynamic Area (closest subject)", 3: "Group Dynamic",
+	4: "Single Area (wide)", 5: "Dynamic Area (wide)",
+}
+
+var nikonAFPoints = map[int]string{
+	0: "Center", 1: "Top", 2: "Bottom", 3: "Mid-left", 4: "Mid-right", 5: "Upper-left",
+	6: "Upper-right", 7: "Lower-left", 8: "Lower-right", 9: "Far Left", 10: "Far Right",
+}
+
+var leicaTags = map[uint16]tagInfo{
+	0x0300: {name: "Quality", group: groupCamera, print: enum(map[int]string{1: "Fine", 2: "Basic"})},
+	0x0302: {name: "UserProfile", group: groupCamera, print: enum(map[int]string{
+		1: "User Profile 1", 2: "User Profile 2", 3: "User Profile 3", 4: "User Profile 0 (Dynamic)",
+	})},
+	0x0303: {name: "LensType", group: groupCamera, print: trimmed},
+	0x0304: {name: "FocusDistance", group: groupCamera, print: func(e *entry) interface{} {
+		return withUnit(e, "m")
+	}},
+	0x0305: {name: "SerialNumber", group: groupCamera},
+	0x0311: {name: "ExternalSensorBrightnessValue", group: groupCamera},
+	0x0312: {name: "MeasuredLV", group: groupCamera},
+	0x0320: {name: "CameraTemperature", group: groupCamera, print: func(e *entry) interface{} {
+		return withUnit(e, "C")
+	}},
+	0x0407: {name: "OriginalFileName", group: groupOther, print: trimmed},
+	0x0408: {name: "OriginalDirectory", group: groupOther, print: trimmed},
+	0x0412: {name: "FilmMode", group: groupCamera, print: trimmed},
+	0x0500: {name: "InternalSerialNumber", group: groupCamera, print: trimmed},
+}
+
+func trimmed(e *entry) interface{} {
+	return value(strings.TrimSpace(e.str()))
+}
+
+// nikonEV prints the int8s[4] exposure values of Nikon maker notes as a fraction
+func nikonEV(e *entry) interface{} {
+	if e.len() < 3 || e.number(2) == 0 {
+		return e.value()
+	}
+	return fraction(float64(int8(e.number(0))) * e.number(1) / e.number(2))
+}
+
+// readNikonPreview reads the preview IFD of Nikon maker notes
+func (m *metadata) readNikonPreview(t *tiff, e *entry) {
+	if entries, _, err := t.readIFD(int64(e.number(0))); err == nil {
+		m.addPreviewIFD(t, entries)
+	}
+}
+
+// addNikonAFInfo adds the AF area mode and AF point of the AFInfo structure
+func (m *metadata) addNikonAFInfo(_ *tiff, e *entry) {
+	if e.len() < 2 {
+		return
+	}
+	if mode, found := nikonAFAreaModes[int(e.number(0))]; found {
+		m.add(groupCamera, "AFAreaMode", mode)
+	}
+	if point, found := nikonAFPoints[int(e.number(1))]; found {
+		m.add(groupCamera, "AFPoint", point)
+	} else {
+		m.add(groupCamera, "AFPoint", fmt.Sprintf("Unknown (%d)", int(e.number(1))))
+	}
+}
@@ -0,0 +1,209 @@
+// Package native reads image metadata without exiftool. The result has the same layout as
+// the output of exiftool -j -g2, tags are grouped by category and printed the way exiftool
+// prints them, so it can be used with mexif.NewExifData.
+package native
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/msvens/mexif/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+const (
+	groupAudio    = "Audio"
+	groupAuthor   = "Author"
+	groupCamera   = "Camera"
+	groupExifTool = "ExifTool"
+	groupImage    = "Image"
+	groupLocation = "Location"
+	groupOther    = "Other"
+	groupPreview  = "Preview"
+	groupTime     = "Time"
+	groupVideo    = "Video"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported file format")
+
+type format struct {
+	fileType  string
+	extension string
+	mimeType  string
+	match     func(header []byte) bool
+	read      func(m *metadata, r io.ReaderAt, size int64) error
+}
+
+var formats = []format{
+	{"JPEG", "jpg", "image/jpeg", func(h []byte) bool { return bytes.HasPrefix(h, []byte{0xff, 0xd8}) }, readJPEG},
+	{"CR2", "cr2", "image/x-canon-cr2", isCR2, readTIFF},
+	{"TIFF", "tif", "image/tiff", isTIFF, readTIFF},
+	{"RAF", "raf", "image/x-fujifilm-raf", func(h []byte) bool { return bytes.HasPrefix(h, rafHeader) }, readRAF},
+	{"PNG", "png", "image/png", func(h []byte) bool { return bytes.HasPrefix(h, pngSignature) }, readPNG},
+	{"WEBP", "webp", "image/webp", isWebP, readWebP},
+	{"XMP", "xmp", "application/rdf+xml", isXMP, readXMPFile},
+	{"HEIC", "heic", "image/heic", isHEIF("HEIC"), readHEIF},
+	{"HEIF", "heif", "image/heif", isHEIF("HEIF"), readHEIF},
+	{"AVIF", "avif", "image/avif", isHEIF("AVIF"), readHEIF},
+	{"CR3", "cr3", "image/x-canon-cr3", isCR3, readCR3},
+	{"MOV", "mov", "video/quicktime", isQuickTime("MOV"), readQuickTime},
+	{"MP4", "mp4", "video/mp4", isQuickTime("MP4"), readQuickTime},
+	{"M4V", "m4v", "video/x-m4v", isQuickTime("M4V"), readQuickTime},
+	{"3GP", "3gp", "video/3gpp", isQuickTime("3GP"), readQuickTime},
+}
+
+// ReadFile reads the metadata of the file at path. Besides the embedded metadata the result
+// holds SourceFile and file tags like FileName and FileModifyDate
+func ReadFile(path string) (json.JSONObject, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s: %w", path, ErrUnsupportedFormat)
+	}
+	m := newMetadata()
+	if err := m.read(f, info.Size()); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	m.set(groupOther, "FileName", filepath.Base(path))
+	m.set(groupOther, "Directory", filepath.Dir(path))
+	m.set(groupTime, "FileModifyDate", info.ModTime().Format("2006:01:02 15:04:05-07:00"))
+	root := m.root()
+	root["SourceFile"] = path
+	return root, nil
+}
+
+// Read reads the metadata of a file with the given size from r
+func Read(r io.ReaderAt, size int64) (json.JSONObject, error) {
+	m := newMetadata()
+	if err := m.read(r, size); err != nil {
+		return nil, err
+	}
+	return m.root(), nil
+}
+
+func ReadBytes(b []byte) (json.JSONObject, error) {
+	return Read(bytes.NewReader(b), int64(len(b)))
+}
+
+// metadata collects the tags of a file by group. As in exiftool a tag name only appears
+// once even if it is found in several groups
+type metadata struct {
+	groups map[string]json.JSONObject
+	tags   map[string]string
+	//previews are the embedded JPEG images besides the thumbnail
+	previews []preview
+}
+
+func newMetadata() *metadata {
+	return &metadata{groups: map[string]json.JSONObject{}, tags: map[string]string{}}
+}
+
+func (m *metadata) read(r io.ReaderAt, size int64) error {
+	header := make([]byte, 16)
+	n, err := r.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	header = header[:n]
+	for _, f := range formats {
+		if !f.match(header) {
+			continue
+		}
+		m.setFormat(f)
+		if err := f.read(m, r, size); err != nil {
+			return err
+		}
+		m.addPreview()
+		m.addComposite()
+		return nil
+	}
+	return ErrUnsupportedFormat
+}
+
+func (m *metadata) setFormat(f format) {
+	m.set(groupOther, "FileType", f.fileType)
+	m.set(groupOther, "FileTypeExtension", f.extension)
+	m.set(groupOther, "MIMEType", f.mimeType)
+}
+
+// set sets a tag, replacing any previous value
+func (m *metadata) set(group, tag string, value interface{}) {
+	if old, found := m.tags[tag]; found && old != group {
+		delete(m.groups[old], tag)
+		if len(m.groups[old]) == 0 {
+			delete(m.groups, old)
+		}
+	}
+	m.tags[tag] = group
+	g, found := m.groups[group]
+	if !found {
+		g = json.JSONObject{}
+		m.groups[group] = g
+	}
+	g[tag] = value
+}
+
+// add sets a tag unless it already has a value. It is used for tags with a lower priority,
+// e.g. the thumbnail IFD or JFIF, that should not replace the main EXIF tags
+func (m *metadata) add(group, tag string, value interface{}) {
+	if _, found := m.tags[tag]; !found {
+		m.set(group, tag, value)
+	}
+}
+
+func (m *metadata) get(group, tag string) (interface{}, bool) {
+	v, found := m.groups[group][tag]
+	return v, found
+}
+
+// find returns a tag from whatever group it is in
+func (m *metadata) find(tag string) (interface{}, bool) {
+	group, found := m.tags[tag]
+	if !found {
+		return nil, false
+	}
+	return m.get(group, tag)
+}
+
+// warn adds a warning to the ExifTool group. As exiftool only the first warning is kept
+func (m *metadata) warn(format string, args ...interface{}) {
+	m.add(groupExifTool, "Warning", fmt.Sprintf(format, args...))
+}
+
+// root returns the groups with the same types encoding/json uses when decoding exiftool output
+func (m *metadata) root() json.JSONObject {
+	root := json.JSONObject{}
+	for name, g := range m.groups {
+		root[name] = map[string]interface{}(g)
+	}
+	return root
+}
+
+// exiftool prints values that look like numbers as JSON numbers
+var jsonNumber = regexp.MustCompile(`^-?(\d|[1-9]\d{1,14})(\.\d{1,16})?([eE][-+]?\d{1,3})?$`)
+
+// value converts a printed value to what it would be after decoding exiftool's JSON output
+func value(s string) interface{} {
+	if jsonNumber.MatchString(s) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return s
+}
+
+// formatNumber formats a number with up to 10 significant digits like exiftool
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'g', 10, 64)
+}
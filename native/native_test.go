@@ -0,0 +1,893 @@
+package native
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"github.com/msvens/mexif/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestReadFile(t *testing.T) {
+	tests := []struct {
+		path     string
+		group    string
+		tag      string
+		expected interface{}
+	}{
+		{"../testdata/DSCF1323.jpg", groupCamera, "Make", "FUJIFILM"},
+		{"../testdata/DSCF1323.jpg", groupCamera, "FocalLength", "35.0 mm"},
+		{"../testdata/DSCF1323.jpg", groupImage, "ExposureCompensation", -0.33},
+		{"../testdata/DSCF1323.jpg", groupImage, "LensInfo", "35mm f/1.4"},
+		{"../testdata/DSCF1323.jpg", groupImage, "ImageSize", "900x1350"},
+		{"../testdata/DSCF1323.jpg", groupTime, "OffsetTime", "+01:00"},
+		{"../testdata/DSC_0685.jpg", groupCamera, "Model", "NIKON D90"},
+		{"../testdata/DSC_0685.jpg", groupCamera, "ExposureProgram", "Aperture-priority AE"},
+		{"../testdata/DSC_0685.jpg", groupImage, "ExposureTime", "1/2000"},
+		{"../testdata/DSC_0685.jpg", groupTime, "SubSecTimeOriginal", "00"},
+		{"../testdata/DSC_0685.jpg", groupOther, "ObjectName", "Portland Bird"},
+		{"../testdata/DSC_0685.jpg", groupLocation, "Province-State", "OR"},
+		{"../testdata/L1000114.jpg", groupCamera, "Flash", "Off, Did not fire"},
+		{"../testdata/L1000114.jpg", groupCamera, "MaxApertureValue", 1.7},
+		{"../testdata/L1000114.jpg", groupImage, "FNumber", 2.5},
+		{"../testdata/L1000114.jpg", groupImage, "ISO", 100.0},
+		{"../testdata/L1000114.jpg", groupTime, "DateTimeOriginal", "2020:01:12 11:31:49"},
+		{"../testdata/L1000114.jpg", groupPreview, "ThumbnailImage", "(Binary data 19152 bytes, use -b option to extract)"},
+		{"../testdata/L1000114.jpg", groupOther, "MIMEType", "image/jpeg"},
+		{"../testdata/L1000114.jpg", groupImage, "Title", "Tree"},
+		{"../testdata/L1000114.jpg", groupImage, "Rating", 3.0},
+		{"../testdata/L1000114.jpg", groupOther, "Subject", "flickr"},
+		{"../testdata/L1000114.jpg", groupTime, "MetadataDate", "2020:01:29 07:53:06+01:00"},
+		{"../testdata/L1000114.jpg", groupTime, "CreateDate", "2020:01:12 11:31:49"},
+	}
+	for _, test := range tests {
+		root, err := ReadFile(test.path)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", test.path, err)
+		}
+		group, _ := json.GetObject(test.group, root)
+		if v := group[test.tag]; v != test.expected {
+			t.Errorf("%s: expected %s %v got %v", test.path, test.tag, test.expected, v)
+		}
+	}
+}
+
+func TestReadUnsupported(t *testing.T) {
+	if _, err := ReadBytes([]byte("not an image")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat got %v", err)
+	}
+	if _, err := ReadBytes([]byte("MM\x00*\x00\x00\x00\x08\x00")); err != nil {
+		t.Errorf("a bad IFD should be a warning got %v", err)
+	}
+}
+
+type testEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+// testTIFF builds a TIFF with IFD0 and an optional GPS IFD
+func testTIFF(order binary.ByteOrder, ifd0 []testEntry, gps []testEntry) []byte {
+	ifdSize := func(entries []testEntry) int { return 2 + 12*len(entries) + 4 }
+	gpsOffset := 0
+	if gps != nil {
+		ifd0 = append(ifd0, testEntry{tagGPSIFD, typeLong, 1, make([]byte, 4)})
+		gpsOffset = 8 + ifdSize(ifd0)
+		order.PutUint32(ifd0[len(ifd0)-1].data, uint32(gpsOffset))
+	}
+	dataOffset := 8 + ifdSize(ifd0)
+	if gps != nil {
+		dataOffset += ifdSize(gps)
+	}
+
+	var data []byte
+	encode := func(entries []testEntry) []byte {
+		b := make([]byte, ifdSize(entries))
+		order.PutUint16(b, uint16(len(entries)))
+		for i, e := range entries {
+			raw := b[2+12*i:]
+			order.PutUint16(raw, e.tag)
+			order.PutUint16(raw[2:], e.typ)
+			order.PutUint32(raw[4:], e.count)
+			if len(e.data) <= 4 {
+				copy(raw[8:], e.data)
+			} else {
+				order.PutUint32(raw[8:], uint32(dataOffset+len(data)))
+				data = append(data, e.data...)
+			}
+		}
+		return b
+	}
+
+	ret := []byte("II*\x00\x08\x00\x00\x00")
+	if order == binary.BigEndian {
+		ret = []byte("MM\x00*\x00\x00\x00\x08")
+	}
+	ret = append(ret, encode(ifd0)...)
+	if gps != nil {
+		ret = append(ret, encode(gps)...)
+	}
+	return append(ret, data...)
+}
+
+func rationals(order binary.ByteOrder, values ...uint32) []byte {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		order.PutUint32(b[4*i:], v)
+	}
+	return b
+}
+
+func TestReadTIFF(t *testing.T) {
+	order := binary.BigEndian
+	short := func(v uint16) []byte {
+		b := make([]byte, 2)
+		order.PutUint16(b, v)
+		return b
+	}
+	b := testTIFF(order, []testEntry{
+		{0x010f, typeASCII, 5, []byte("Test\x00")},
+		{0x0112, typeShort, 1, short(6)},
+		{0x9c9d, typeByte, 8, []byte{'A', 0, 'n', 0, 'n', 0, 0, 0}},
+	}, []testEntry{
+		{0x01, typeASCII, 2, []byte("S\x00")},
+		{0x02, typeRational, 3, rationals(order, 33, 1, 52, 1, 420, 100)},
+		{0x03, typeASCII, 2, []byte("E\x00")},
+		{0x04, typeRational, 3, rationals(order, 151, 1, 12, 1, 36, 1)},
+		{0x05, typeByte, 1, []byte{1}},
+		{0x06, typeRational, 1, rationals(order, 125, 10)},
+	})
+	root, err := ReadBytes(b)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	camera, _ := json.GetObject(groupCamera, root)
+	image, _ := json.GetObject(groupImage, root)
+	author, _ := json.GetObject(groupAuthor, root)
+	location, _ := json.GetObject(groupLocation, root)
+	other, _ := json.GetObject(groupOther, root)
+	if camera["Make"] != "Test" || image["Orientation"] != "Rotate 90 CW" || author["XPAuthor"] != "Ann" {
+		t.Errorf("unexpected IFD0 tags %v %v %v", camera, image, author)
+	}
+	if other["ExifByteOrder"] != "Big-endian (Motorola, MM)" || other["FileType"] != "TIFF" {
+		t.Errorf("unexpected file tags %v", other)
+	}
+	if location["GPSLatitude"] != `33 deg 52' 4.20" S` || location["GPSLongitudeRef"] != "East" ||
+		location["GPSAltitude"] != "12.5 m Below Sea Level" {
+		t.Errorf("unexpected GPS tags %v", location)
+	}
+	if lat, err := json.GetCoordinate("GPSLatitude", "GPSLatitudeRef", location); err != nil || math.Abs(lat+33.867833) > 1e-6 {
+		t.Errorf("expected latitude -33.867833 got %v %v", lat, err)
+	}
+}
+
+func TestPrintConversions(t *testing.T) {
+	if s := exposureTime(1.0 / 250); s != "1/250" {
+		t.Errorf("expected 1/250 got %s", s)
+	}
+	if s := exposureTime(2.5); s != "2.5" {
+		t.Errorf("expected 2.5 got %s", s)
+	}
+	if s := exposureTime(30); s != "30" {
+		t.Errorf("expected 30 got %s", s)
+	}
+	for v, expected := range map[float64]string{0: "0", 1.0 / 3: "+1/3", -0.5: "-1/2", 2: "+2", -0.7: "-0.7"} {
+		if s := fraction(v); s != expected {
+			t.Errorf("expected %s for %v got %s", expected, v, s)
+		}
+	}
+	if s := dms(59.99999999); s != `60 deg 0' 0.00"` {
+		t.Errorf("expected 60 deg got %s", s)
+	}
+}
+
+// testIFD builds an IFD at offset followed by the values that do not fit in the entries
+func testIFD(order binary.ByteOrder, offset int, entries []testEntry) []byte {
+	b := make([]byte, 2+12*len(entries)+4)
+	order.PutUint16(b, uint16(len(entries)))
+	for i, e := range entries {
+		raw := b[2+12*i:]
+		order.PutUint16(raw, e.tag)
+		order.PutUint16(raw[2:], e.typ)
+		order.PutUint32(raw[4:], e.count)
+		if len(e.data) <= 4 {
+			copy(raw[8:], e.data)
+		} else {
+			order.PutUint32(raw[8:], uint32(offset+len(b)))
+			b = append(b, e.data...)
+		}
+	}
+	return b
+}
+
+// testMakerNote builds a TIFF with a Make tag and an ExifIFD with the maker note returned by
+// note for the offset of the maker note
+func testMakerNote(camera string, note func(offset int) []byte) []byte {
+	order := binary.LittleEndian
+	ifd0 := func(exifOffset int) []byte {
+		return testIFD(order, 8, []testEntry{
+			{0x010f, typeASCII, uint32(len(camera) + 1), []byte(camera + "\x00")},
+			{tagExifIFD, typeLong, 1, rationals(order, uint32(exifOffset))},
+		})
+	}
+	exifOffset := 8 + len(ifd0(0))
+	data := note(exifOffset + 2 + 12 + 4)
+	exif := testIFD(order, exifOffset, []testEntry{{tagMakerNote, typeUndefined, uint32(len(data)), data}})
+	ret := append([]byte("II*\x00\x08\x00\x00\x00"), ifd0(exifOffset)...)
+	return append(ret, exif...)
+}
+
+func TestReadMakerNote(t *testing.T) {
+	short := func(order binary.ByteOrder, v uint16) []byte {
+		b := make([]byte, 2)
+		order.PutUint16(b, v)
+		return b
+	}
+	le, be := binary.LittleEndian, binary.BigEndian
+	fuji := testMakerNote("FUJIFILM", func(int) []byte {
+		//offsets are relative to the start of the maker note
+		return append([]byte("FUJIFILM\x0c\x00\x00\x00"), testIFD(le, 12, []testEntry{
+			{0x1000, typeASCII, 8, []byte("NORMAL \x00")},
+			{0x1023, typeShort, 2, append(short(le, 1536), short(le, 1024)...)},
+			{0x1401, typeShort, 1, short(le, 0x600)},
+			{0x1438, typeLong, 1, rationals(le, 0x8000|1234)},
+		})...)
+	})
+	nikon := testMakerNote("NIKON CORPORATION", func(int) []byte {
+		//offsets are relative to the embedded big-endian TIFF header
+		note := []byte("Nikon\x00\x02\x10\x00\x00MM\x00*\x00\x00\x00\x08")
+		return append(note, testIFD(be, 8, []testEntry{
+			{0x0001, typeUndefined, 4, []byte("0210")},
+			{0x001d, typeASCII, 8, []byte("3012345\x00")},
+			{0x0084, typeRational, 4, rationals(be, 18, 1, 55, 1, 35, 10, 56, 10)},
+			{0x0088, typeUndefined, 4, []byte{0, 3, 0, 0}},
+			{0x00a7, typeLong, 1, rationals(be, 12345)},
+		})...)
+	})
+	leica := testMakerNote("LEICA CAMERA AG", func(offset int) []byte {
+		//offsets are relative to the TIFF header
+		return append([]byte("LEICA\x00\x00\x00"), testIFD(le, offset+8, []testEntry{
+			{0x0303, typeASCII, 17, []byte("Summilux-M 1:1.4\x00")},
+			{0x0305, typeLong, 1, rationals(le, 4123456)},
+		})...)
+	})
+
+	for _, test := range []struct {
+		data     []byte
+		expected map[string]interface{}
+	}{
+		{fuji, map[string]interface{}{"Quality": "NORMAL", "FocusPixel": "1536 1024", "FilmMode": "Classic Chrome",
+			"ImageCount": float64(1234)}},
+		{nikon, map[string]interface{}{"MakerNoteVersion": 2.1, "SerialNumber": float64(3012345),
+			"Lens": "18-55mm f/3.5-5.6", "AFAreaMode": "Single Area", "AFPoint": "Mid-left", "ShutterCount": float64(12345)}},
+		{leica, map[string]interface{}{"LensType": "Summilux-M 1:1.4", "SerialNumber": float64(4123456)}},
+	} {
+		root, err := ReadBytes(test.data)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		camera, _ := json.GetObject(groupCamera, root)
+		for tag, expected := range test.expected {
+			if camera[tag] != expected {
+				t.Errorf("expected %s %v got %v", tag, expected, camera[tag])
+			}
+		}
+	}
+	//maker notes of other cameras are ignored
+	other := testMakerNote("Canon", func(int) []byte { return []byte("FUJIFILM\x0c\x00\x00\x00") })
+	root, err := ReadBytes(other)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if camera, _ := json.GetObject(groupCamera, root); len(camera) != 1 {
+		t.Errorf("expected only Make got %v", camera)
+	}
+}
+
+const testXMP = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/" x:xmptk="Test Toolkit">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:exif="http://ns.adobe.com/exif/1.0/"
+    xmlns:Iptc4xmpCore="http://iptc.org/std/Iptc4xmpCore/1.0/xmlns/"
+    xmp:Rating="4" xmp:CreateDate="2021-06-01T10:20:30.5+02:00">
+   <dc:title>
+    <rdf:Alt>
+     <rdf:li xml:lang="x-default">Harbour</rdf:li>
+     <rdf:li xml:lang="sv">Hamnen</rdf:li>
+    </rdf:Alt>
+   </dc:title>
+   <dc:subject>
+    <rdf:Bag><rdf:li>sea</rdf:li><rdf:li>boat</rdf:li></rdf:Bag>
+   </dc:subject>
+   <dc:creator><rdf:Seq><rdf:li>Ann</rdf:li></rdf:Seq></dc:creator>
+   <Iptc4xmpCore:CreatorContactInfo rdf:parseType="Resource">
+    <Iptc4xmpCore:CiAdrCity>Stockholm</Iptc4xmpCore:CiAdrCity>
+   </Iptc4xmpCore:CreatorContactInfo>
+   <exif:GPSLatitude>59,19.741N</exif:GPSLatitude>
+   <exif:ExposureTime>1/125</exif:ExposureTime>
+   <exif:FNumber>28/10</exif:FNumber>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+func TestReadXMP(t *testing.T) {
+	root, err := ReadBytes([]byte(testXMP))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	tests := []struct {
+		group    string
+		tag      string
+		expected interface{}
+	}{
+		{groupOther, "FileType", "XMP"},
+		{groupOther, "XMPToolkit", "Test Toolkit"},
+		{groupOther, "Subject", []interface{}{"sea", "boat"}},
+		{groupImage, "Title", "Harbour"},
+		{groupImage, "Title-sv", "Hamnen"},
+		{groupImage, "Rating", 4.0},
+		{groupImage, "ExposureTime", "1/125"},
+		{groupImage, "FNumber", 2.8},
+		{groupAuthor, "Creator", "Ann"},
+		{groupAuthor, "CreatorCity", "Stockholm"},
+		{groupTime, "CreateDate", "2021:06:01 10:20:30.5+02:00"},
+		{groupLocation, "GPSLatitude", `59 deg 19' 44.46" N`},
+	}
+	for _, test := range tests {
+		group, _ := json.GetObject(test.group, root)
+		if v := group[test.tag]; !reflect.DeepEqual(v, test.expected) {
+			t.Errorf("expected %s %v got %v", test.tag, test.expected, v)
+		}
+	}
+}
+
+func pngChunk(typ string, data []byte) []byte {
+	b := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(b, uint32(len(data)))
+	copy(b[4:], typ)
+	return append(append(b, data...), 0, 0, 0, 0)
+}
+
+func compress(s string) []byte {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write([]byte(s))
+	zw.Close()
+	return z.Bytes()
+}
+
+// testPNG builds a PNG with a header, a compressed XMP iTXt chunk and the given chunks. CRCs
+// are not checked
+func testPNG(xmp string, chunks ...[]byte) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr, 640)
+	binary.BigEndian.PutUint32(ihdr[4:], 480)
+	ihdr[8], ihdr[9] = 8, 6
+	itxt := append([]byte(pngXMPKeyword+"\x00\x01\x00\x00\x00"), compress(xmp)...)
+
+	ret := append([]byte{}, pngSignature...)
+	ret = append(ret, pngChunk("IHDR", ihdr)...)
+	ret = append(ret, pngChunk("iTXt", itxt)...)
+	for _, c := range chunks {
+		ret = append(ret, c...)
+	}
+	return append(ret, pngChunk("IEND", nil)...)
+}
+
+// testICC builds an ICC profile with a version 2 description
+func testICC(description string) []byte {
+	b := make([]byte, 132+12)
+	binary.BigEndian.PutUint32(b[128:], 1)
+	copy(b[132:], "desc")
+	binary.BigEndian.PutUint32(b[136:], uint32(len(b)))
+	binary.BigEndian.PutUint32(b[140:], uint32(12+len(description)+1))
+	b = append(b, "desc\x00\x00\x00\x00"...)
+	b = append(b, rationals(binary.BigEndian, uint32(len(description)+1))...)
+	return append(b, description+"\x00"...)
+}
+
+func TestReadPNG(t *testing.T) {
+	tiff := testTIFF(binary.BigEndian, []testEntry{{0x010f, typeASCII, 5, []byte("Test\x00")}}, nil)
+	phys := append(rationals(binary.BigEndian, 3780, 3780), 1)
+	root, err := ReadBytes(testPNG(testXMP,
+		pngChunk("eXIf", tiff),
+		pngChunk("tEXt", []byte("Author\x00Bj\xf6rn")),
+		pngChunk("tEXt", []byte("date:create\x002021-06-01T10:20:30+00:00")),
+		pngChunk("zTXt", append([]byte("Comment\x00\x00"), compress("A comment")...)),
+		pngChunk("pHYs", phys),
+		pngChunk("iCCP", append([]byte("ICC profile\x00\x00"), compress(string(testICC("sRGB IEC61966-2.1")))...)),
+	))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	tests := []struct {
+		group    string
+		tag      string
+		expected interface{}
+	}{
+		{groupOther, "FileType", "PNG"},
+		{groupImage, "ImageSize", "640x480"},
+		{groupImage, "ColorType", "RGB with Alpha"},
+		{groupImage, "Title", "Harbour"},
+		{groupCamera, "Make", "Test"},
+		{groupAuthor, "Author", "Björn"},
+		{groupImage, "Datecreate", "2021-06-01T10:20:30+00:00"},
+		{groupImage, "Comment", "A comment"},
+		{groupImage, "PixelsPerUnitX", 3780.0},
+		{groupImage, "PixelUnits", "meters"},
+		{groupImage, "ProfileName", "ICC profile"},
+		{groupImage, "ProfileDescription", "sRGB IEC61966-2.1"},
+	}
+	for _, test := range tests {
+		group, _ := json.GetObject(test.group, root)
+		if v := group[test.tag]; v != test.expected {
+			t.Errorf("expected %s %v got %v", test.tag, test.expected, v)
+		}
+	}
+}
+
+// testWebP builds an extended WebP file with EXIF and XMP chunks
+func testWebP(exif []byte, xmp string) []byte {
+	chunk := func(typ string, data []byte) []byte {
+		b := make([]byte, 8, 8+len(data)+1)
+		copy(b, typ)
+		binary.LittleEndian.PutUint32(b[4:], uint32(len(data)))
+		b = append(b, data...)
+		if len(data)%2 == 1 {
+			b = append(b, 0)
+		}
+		return b
+	}
+	//a 1024x768 canvas, stored as width and height minus one
+	vp8x := []byte{0x0c, 0, 0, 0, 0xff, 0x03, 0, 0xff, 0x02, 0}
+	body := []byte("WEBP")
+	body = append(body, chunk("VP8X", vp8x)...)
+	body = append(body, chunk("EXIF", exif)...)
+	body = append(body, chunk("XMP ", []byte(xmp))...)
+	ret := []byte("RIFF\x00\x00\x00\x00")
+	binary.LittleEndian.PutUint32(ret[4:], uint32(len(body)))
+	return append(ret, body...)
+}
+
+func TestReadWebP(t *testing.T) {
+	tiff := testTIFF(binary.LittleEndian, []testEntry{{0x010f, typeASCII, 5, []byte("Test\x00")}}, nil)
+	root, err := ReadBytes(testWebP(append([]byte("Exif\x00\x00"), tiff...), testXMP))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	image, _ := json.GetObject(groupImage, root)
+	camera, _ := json.GetObject(groupCamera, root)
+	other, _ := json.GetObject(groupOther, root)
+	if other["FileType"] != "WEBP" || other["MIMEType"] != "image/webp" {
+		t.Errorf("unexpected file tags %v", other)
+	}
+	if image["ImageSize"] != "1024x768" || image["Title"] != "Harbour" || camera["Make"] != "Test" {
+		t.Errorf("unexpected WebP tags %v %v", image, camera)
+	}
+}
+
+// testJPEG builds a JPEG with an APP13 segment holding the IPTC datasets in an 8BIM resource
+func testJPEG(datasets ...[]byte) []byte {
+	var iptc []byte
+	for _, d := range datasets {
+		iptc = append(iptc, d...)
+	}
+	resource := append([]byte("8BIM\x04\x04\x00\x00"), make([]byte, 4)...)
+	binary.BigEndian.PutUint32(resource[8:], uint32(len(iptc)))
+	resource = append(resource, iptc...)
+	if len(iptc)%2 == 1 {
+		resource = append(resource, 0)
+	}
+	segment := append([]byte(photoshopHeader), resource...)
+
+	ret := []byte{0xff, 0xd8, 0xff, 0xed, 0, 0}
+	binary.BigEndian.PutUint16(ret[4:], uint16(len(segment)+2))
+	ret = append(ret, segment...)
+	return append(ret, 0xff, 0xd9)
+}
+
+func dataset(record, number byte, value string) []byte {
+	b := []byte{0x1c, record, number, 0, 0}
+	binary.BigEndian.PutUint16(b[3:], uint16(len(value)))
+	return append(b, value...)
+}
+
+func TestReadIPTC(t *testing.T) {
+	root, err := ReadBytes(testJPEG(
+		dataset(1, 90, "\x1b%G"),
+		dataset(2, 0, "\x00\x04"),
+		dataset(2, 5, "Göteborg harbour"),
+		dataset(2, 25, "sea"),
+		dataset(2, 25, "boat"),
+		dataset(2, 55, "20210601"),
+		dataset(2, 60, "102030+0200"),
+		dataset(2, 80, "Ann"),
+		dataset(2, 90, "Göteborg"),
+		dataset(2, 101, "Sweden"),
+	))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	tests := []struct {
+		group    string
+		tag      string
+		expected interface{}
+	}{
+		{groupOther, "CodedCharacterSet", "UTF8"},
+		{groupOther, "ApplicationRecordVersion", 4.0},
+		{groupOther, "ObjectName", "Göteborg harbour"},
+		{groupOther, "Keywords", []interface{}{"sea", "boat"}},
+		{groupAuthor, "By-line", "Ann"},
+		{groupLocation, "City", "Göteborg"},
+		{groupLocation, "Country-PrimaryLocationName", "Sweden"},
+		{groupTime, "DateCreated", "2021:06:01"},
+		{groupTime, "TimeCreated", "10:20:30+02:00"},
+		{groupTime, "DateTimeCreated", "2021:06:01 10:20:30+02:00"},
+	}
+	for _, test := range tests {
+		group, _ := json.GetObject(test.group, root)
+		if v := group[test.tag]; !reflect.DeepEqual(v, test.expected) {
+			t.Errorf("expected %s %v got %v", test.tag, test.expected, v)
+		}
+	}
+
+	//without 1:90 the strings are ISO-8859-1
+	root, err = ReadBytes(testJPEG(dataset(2, 90, "G\xf6teborg")))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	location, _ := json.GetObject(groupLocation, root)
+	if location["City"] != "Göteborg" {
+		t.Errorf("expected Göteborg got %v", location["City"])
+	}
+}
+
+func testBox(typ string, payload ...[]byte) []byte {
+	b := make([]byte, 8)
+	copy(b[4:], typ)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(b)))
+	return b
+}
+
+func uint16s(values ...uint16) []byte {
+	b := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint16(b[2*i:], v)
+	}
+	return b
+}
+
+// testHEIF builds a HEIC file with an image, an Exif item in the mdat box and an XMP item
+// in the idat box
+func testHEIF(exif []byte, xmp string) []byte {
+	ftyp := testBox("ftyp", []byte("heic\x00\x00\x00\x00mif1heic"))
+	infe := func(id uint16, typ string, extra string) []byte {
+		return testBox("infe", []byte{2, 0, 0, 0}, uint16s(id, 0), []byte(typ+"\x00"+extra))
+	}
+	iinf := testBox("iinf", []byte{0, 0, 0, 0}, uint16s(3),
+		infe(1, "hvc1", ""), infe(2, "Exif", ""), infe(3, "mime", "application/rdf+xml\x00"))
+	ipco := testBox("ipco",
+		testBox("ispe", []byte{0, 0, 0, 0}, rationals(binary.BigEndian, 4032, 3024)),
+		testBox("irot", []byte{3}))
+	ipma := testBox("ipma", []byte{0, 0, 0, 0}, rationals(binary.BigEndian, 1), uint16s(1), []byte{2, 0x81, 0x02})
+	idat := testBox("idat", []byte(xmp))
+	iloc := func(exifOffset int) []byte {
+		//version 1 with 4 byte offsets and lengths and no base offset
+		return testBox("iloc", []byte{1, 0, 0, 0, 0x44, 0x00}, uint16s(2),
+			uint16s(2, 0, 0, 1), rationals(binary.BigEndian, uint32(exifOffset), uint32(len(exif))),
+			uint16s(3, 1, 0, 1), rationals(binary.BigEndian, 0, uint32(len(xmp))))
+	}
+	meta := func(exifOffset int) []byte {
+		return testBox("meta", []byte{0, 0, 0, 0}, testBox("pitm", []byte{0, 0, 0, 0}, uint16s(1)),
+			iinf, iloc(exifOffset), testBox("iprp", ipco, ipma), idat)
+	}
+	offset := len(ftyp) + len(meta(0)) + 8
+	ret := append(ftyp, meta(offset)...)
+	return append(ret, testBox("mdat", exif)...)
+}
+
+func TestReadHEIF(t *testing.T) {
+	tiff := testTIFF(binary.LittleEndian, []testEntry{{0x010f, typeASCII, 6, []byte("Apple\x00")}}, nil)
+	exif := append([]byte{0, 0, 0, 6}, append([]byte("Exif\x00\x00"), tiff...)...)
+	root, err := ReadBytes(testHEIF(exif, testXMP))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	tests := []struct {
+		group    string
+		tag      string
+		expected interface{}
+	}{
+		{groupOther, "FileType", "HEIC"},
+		{groupOther, "MIMEType", "image/heic"},
+		{groupCamera, "Make", "Apple"},
+		{groupImage, "ImageSize", "4032x3024"},
+		{groupImage, "Rotation", "Rotate 90 CW"},
+		{groupImage, "Title", "Harbour"},
+	}
+	for _, test := range tests {
+		group, _ := json.GetObject(test.group, root)
+		if v := group[test.tag]; v != test.expected {
+			t.Errorf("expected %s %v got %v", test.tag, test.expected, v)
+		}
+	}
+}
+
+// testQuickTime builds a QuickTime movie with a rotated video track, a sound track and Apple
+// metadata keys
+func testQuickTime(keys map[string]string) []byte {
+	be := binary.BigEndian
+	u32 := func(values ...uint32) []byte { return rationals(be, values...) }
+	//2021-06-01 10:20:30 UTC in seconds since 1904
+	created := uint32(3705387630)
+	mvhd := testBox("mvhd", u32(0, created, created, 600, 3600), make([]byte, 80))
+	matrix := u32(0, 0x10000, 0, 0xffff0000, 0, 0, 0, 0, 0x40000000)
+	tkhd := testBox("tkhd", u32(0, created, created, 1, 0, 3600), make([]byte, 16), matrix, u32(1920<<16, 1080<<16))
+	video := make([]byte, 78)
+	be.PutUint16(video[24:], 1920)
+	be.PutUint16(video[26:], 1080)
+	be.PutUint32(video[28:], 72<<16)
+	be.PutUint32(video[32:], 72<<16)
+	be.PutUint16(video[76:], 24)
+	stsd := testBox("stsd", u32(0, 1, uint32(8+len(video))), []byte("avc1"), video)
+	stts := testBox("stts", u32(0, 1, 180, 20))
+	vide := testBox("trak", tkhd, testBox("mdia",
+		testBox("mdhd", u32(0, created, created, 600, 3600), make([]byte, 4)),
+		testBox("hdlr", u32(0, 0), []byte("vide"), make([]byte, 12)),
+		testBox("minf", testBox("stbl", stsd, stts))))
+	audio := make([]byte, 28)
+	be.PutUint16(audio[16:], 2)
+	be.PutUint16(audio[18:], 16)
+	be.PutUint32(audio[24:], 44100<<16)
+	soun := testBox("trak", testBox("mdia",
+		testBox("hdlr", u32(0, 0), []byte("soun"), make([]byte, 12)),
+		testBox("minf", testBox("stbl", testBox("stsd", u32(0, 1, uint32(8+len(audio))), []byte("mp4a"), audio)))))
+
+	var keyList, items []byte
+	i := uint32(0)
+	for k, v := range keys {
+		i++
+		keyList = append(keyList, u32(uint32(8+len(k)))...)
+		keyList = append(keyList, []byte("mdta"+k)...)
+		item := testBox("data", u32(1, 0), []byte(v))
+		items = append(items, testBox(string(u32(i)), item)...)
+	}
+	meta := testBox("meta", testBox("hdlr", u32(0, 0), []byte("mdta"), make([]byte, 12)),
+		testBox("keys", u32(0, i), keyList), testBox("ilst", items))
+
+	ret := testBox("ftyp", []byte("qt  \x00\x00\x00\x00qt  "))
+	return append(ret, testBox("moov", mvhd, vide, soun, meta)...)
+}
+
+func TestReadQuickTime(t *testing.T) {
+	root, err := ReadBytes(testQuickTime(map[string]string{
+		"com.apple.quicktime.make":             "Apple",
+		"com.apple.quicktime.model":            "iPhone 12",
+		"com.apple.quicktime.creationdate":     "2021-06-01T12:20:30+0200",
+		"com.apple.quicktime.location.ISO6709": "+59.3290+018.0687+025.000/",
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	tests := []struct {
+		group    string
+		tag      string
+		expected interface{}
+	}{
+		{groupOther, "FileType", "MOV"},
+		{groupOther, "MIMEType", "video/quicktime"},
+		{groupVideo, "Duration", "6.00 s"},
+		{groupVideo, "ImageWidth", 1920.0},
+		{groupVideo, "ImageHeight", 1080.0},
+		{groupVideo, "Rotation", 90.0},
+		{groupVideo, "CompressorID", "avc1"},
+		{groupVideo, "VideoFrameRate", 30.0},
+		{groupAudio, "AudioFormat", "mp4a"},
+		{groupAudio, "AudioChannels", 2.0},
+		{groupAudio, "AudioSampleRate", 44100.0},
+		{groupImage, "ImageSize", "1920x1080"},
+		{groupCamera, "Make", "Apple"},
+		{groupCamera, "Model", "iPhone 12"},
+		{groupTime, "CreateDate", "2021:06:01 10:20:30"},
+		{groupTime, "CreationDate", "2021:06:01 12:20:30+02:00"},
+		{groupLocation, "GPSCoordinates", `59 deg 19' 44.40" N, 18 deg 4' 7.32" E, 25 m Above Sea Level`},
+		{groupLocation, "GPSPosition", `59 deg 19' 44.40" N, 18 deg 4' 7.32" E`},
+		{groupLocation, "GPSAltitude", "25 m Above Sea Level"},
+	}
+	for _, test := range tests {
+		group, _ := json.GetObject(test.group, root)
+		if v := group[test.tag]; v != test.expected {
+			t.Errorf("expected %s %v got %v", test.tag, test.expected, v)
+		}
+	}
+	if s := quickTimeDuration(65.4); s != "0:01:05" {
+		t.Errorf("expected 0:01:05 got %s", s)
+	}
+}
+
+// testImage builds a JPEG with a start of frame segment of the given marker and an optional
+// EXIF segment
+func testImage(sof byte, exif []byte) []byte {
+	ret := []byte{0xff, 0xd8}
+	if exif != nil {
+		ret = append(ret, 0xff, 0xe1)
+		ret = append(ret, uint16s(uint16(len(exif)+8))...)
+		ret = append(ret, exifHeader...)
+		ret = append(ret, exif...)
+	}
+	ret = append(ret, 0xff, sof, 0, 11, 8, 0, 16, 0, 32, 1, 1, 0x11, 0)
+	return append(ret, 0xff, 0xd9)
+}
+
+// testRaw builds a big-endian TIFF with a reduced resolution IFD0 and two SubIFDs, a JPEG
+// preview and the full resolution raw image stored as a lossless JPEG
+func testRaw(camera string, extra ...testEntry) []byte {
+	be := binary.BigEndian
+	long := func(values ...uint32) []byte { return rationals(be, values...) }
+	preview, raw := testImage(0xc0, nil), testImage(0xc3, nil)
+	previewOffset, rawOffset := 8, 8+len(preview)
+	ifdOffset := rawOffset + len(raw)
+
+	sub1 := testIFD(be, 0, []testEntry{
+		{tagSubfileType, typeLong, 1, long(1)},
+		{tagCompression, typeShort, 1, uint16s(7)},
+		{tagStripOffsets, typeLong, 1, long(uint32(previewOffset))},
+		{tagStripByteCounts, typeLong, 1, long(uint32(len(preview)))},
+	})
+	sub2 := testIFD(be, 0, []testEntry{
+		{tagSubfileType, typeLong, 1, long(0)},
+		{0x0100, typeLong, 1, long(6000)},
+		{tagCompression, typeShort, 1, uint16s(7)},
+		{0x0106, typeShort, 1, uint16s(32803)},
+		{tagStripOffsets, typeLong, 1, long(uint32(rawOffset))},
+		{tagStripByteCounts, typeLong, 1, long(uint32(len(raw)))},
+	})
+	ifd0 := func(subOffset int) []byte {
+		return testIFD(be, ifdOffset, append([]testEntry{
+			{tagSubfileType, typeLong, 1, long(1)},
+			{0x0100, typeLong, 1, long(256)},
+			{0x010f, typeASCII, uint32(len(camera) + 1), []byte(camera + "\x00")},
+			{tagSubIFDs, typeLong, 2, long(uint32(subOffset), uint32(subOffset+len(sub1)))},
+		}, extra...))
+	}
+	subOffset := ifdOffset + len(ifd0(0))
+
+	ret := append([]byte("MM\x00*"), long(uint32(ifdOffset))...)
+	for _, b := range [][]byte{preview, raw, ifd0(subOffset), sub1, sub2} {
+		ret = append(ret, b...)
+	}
+	return ret
+}
+
+func TestReadRaw(t *testing.T) {
+	previewLength := float64(len(testImage(0xc0, nil)))
+	for _, test := range []struct {
+		data     []byte
+		fileType string
+	}{
+		{testRaw("Test", testEntry{0xc612, typeByte, 4, []byte{1, 4, 0, 0}}), "DNG"},
+		{testRaw("NIKON CORPORATION"), "NEF"},
+		{testRaw("SONY"), "ARW"},
+		{testRaw("Test"), "TIFF"},
+	} {
+		root, err := ReadBytes(test.data)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		other, _ := json.GetObject(groupOther, root)
+		image, _ := json.GetObject(groupImage, root)
+		preview, _ := json.GetObject(groupPreview, root)
+		if other["FileType"] != test.fileType {
+			t.Errorf("expected file type %s got %v", test.fileType, other["FileType"])
+		}
+		//the full resolution SubIFD has priority over IFD0
+		if image["ImageWidth"] != 6000.0 || image["SubfileType"] != "Full-resolution image" {
+			t.Errorf("unexpected image tags %v", image)
+		}
+		//the lossless JPEG of the raw image is not a preview
+		if image["PreviewImageStart"] != 8.0 || image["PreviewImageLength"] != previewLength ||
+			preview["PreviewImage"] != binaryData(int64(previewLength)) {
+			t.Errorf("unexpected preview %v %v", image, preview)
+		}
+	}
+}
+
+func TestReadCR2(t *testing.T) {
+	le := binary.LittleEndian
+	image := testImage(0xc0, nil)
+	entries := func(offset int) []testEntry {
+		return []testEntry{
+			{0x010f, typeASCII, 6, []byte("Canon\x00")},
+			{tagCompression, typeShort, 1, []byte{6, 0}},
+			{tagStripOffsets, typeLong, 1, rationals(le, uint32(offset))},
+			{tagStripByteCounts, typeLong, 1, rationals(le, uint32(len(image)))},
+		}
+	}
+	offset := 16 + len(testIFD(le, 16, entries(0)))
+	b := append([]byte("II*\x00\x10\x00\x00\x00CR\x02\x00\x00\x00\x00\x00"), testIFD(le, 16, entries(offset))...)
+	root, err := ReadBytes(append(b, image...))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	other, _ := json.GetObject(groupOther, root)
+	camera, _ := json.GetObject(groupCamera, root)
+	preview, _ := json.GetObject(groupPreview, root)
+	if other["FileType"] != "CR2" || camera["Make"] != "Canon" || preview["PreviewImage"] == nil {
+		t.Errorf("unexpected result %v", root)
+	}
+}
+
+func TestReadRAF(t *testing.T) {
+	exif := testTIFF(binary.BigEndian, []testEntry{{0x010f, typeASCII, 9, []byte("FUJIFILM\x00")}}, nil)
+	image := testImage(0xc0, exif)
+	dir := append(rationals(binary.BigEndian, 2), append(uint16s(0x100, 4), uint16s(4182, 6384)...)...)
+	dir = append(dir, append(uint16s(0x111, 4), uint16s(4160, 6240)...)...)
+
+	header := make([]byte, 0x64)
+	copy(header, rafHeader)
+	binary.BigEndian.PutUint32(header[0x54:], 0x64)
+	binary.BigEndian.PutUint32(header[0x58:], uint32(len(image)))
+	binary.BigEndian.PutUint32(header[0x5c:], uint32(0x64+len(image)))
+	binary.BigEndian.PutUint32(header[0x60:], uint32(len(dir)))
+	root, err := ReadBytes(append(append(header, image...), dir...))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	other, _ := json.GetObject(groupOther, root)
+	camera, _ := json.GetObject(groupCamera, root)
+	img, _ := json.GetObject(groupImage, root)
+	if other["FileType"] != "RAF" || camera["Make"] != "FUJIFILM" {
+		t.Errorf("unexpected tags %v %v", other, camera)
+	}
+	if img["RawImageFullSize"] != "6384x4182" || img["ImageSize"] != "6240x4160" || img["ImageWidth"] != 32.0 {
+		t.Errorf("unexpected image tags %v", img)
+	}
+	if img["PreviewImageStart"] != float64(0x64) || img["PreviewImageLength"] != float64(len(image)) {
+		t.Errorf("unexpected preview %v", img)
+	}
+}
+
+func TestReadCR3(t *testing.T) {
+	le := binary.LittleEndian
+	jpeg := func(lengthOffset int) []byte {
+		image := testImage(0xc0, nil)
+		header := make([]byte, 16)
+		binary.BigEndian.PutUint32(header[lengthOffset:], uint32(len(image)))
+		return append(header, image...)
+	}
+	cmt1 := testTIFF(le, []testEntry{{0x010f, typeASCII, 6, []byte("Canon\x00")}}, nil)
+	cmt2 := testTIFF(le, []testEntry{{0x8827, typeShort, 1, []byte{200, 0}}}, nil)
+	b := testBox("ftyp", []byte("crx \x00\x00\x00\x01crx isom"))
+	b = append(b, testBox("moov", testBox("uuid", canonUUID, testBox("CMT1", cmt1), testBox("CMT2", cmt2),
+		testBox("THMB", jpeg(8))))...)
+	b = append(b, testBox("uuid", previewUUID, make([]byte, 8), testBox("PRVW", jpeg(12)))...)
+	root, err := ReadBytes(b)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	other, _ := json.GetObject(groupOther, root)
+	camera, _ := json.GetObject(groupCamera, root)
+	image, _ := json.GetObject(groupImage, root)
+	preview, _ := json.GetObject(groupPreview, root)
+	if other["FileType"] != "CR3" || other["ExifByteOrder"] != "Little-endian (Intel, II)" {
+		t.Errorf("unexpected tags %v", other)
+	}
+	if camera["Make"] != "Canon" || image["ISO"] != 200.0 {
+		t.Errorf("unexpected EXIF tags %v %v", camera, image)
+	}
+	if preview["ThumbnailImage"] == nil || preview["PreviewImage"] == nil {
+		t.Errorf("unexpected preview tags %v", preview)
+	}
+}
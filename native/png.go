@@ -0,0 +1,279 @@
+package native
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+const pngXMPKeyword = "XML:com.adobe.xmp"
+
+var pngColorTypes = map[byte]string{
+	0: "Grayscale", 2: "RGB", 3: "Palette", 4: "Grayscale with Alpha", 6: "RGB with Alpha",
+}
+
+// pngTextTags are the known keywords of PNG text chunks. Other keywords are used as tag
+// names in the Image group
+var pngTextTags = map[string]struct{ name, group string }{
+	"Author":        {"Author", groupAuthor},
+	"Copyright":     {"Copyright", groupAuthor},
+	"Creation Time": {"CreationTime", groupTime},
+	"Description":   {"Description", groupImage},
+	"Software":      {"Software", groupImage},
+	"Source":        {"Source", groupAuthor},
+	"Title":         {"Title", groupImage},
+}
+
+// readPNG reads the chunks of a PNG file. The image data chunks are skipped
+func readPNG(m *metadata, r io.ReaderAt, size int64) error {
+	pos := int64(len(pngSignature))
+	header := make([]byte, 8)
+	for pos+12 <= size {
+		if _, err := r.ReadAt(header, pos); err != nil {
+			return err
+		}
+		length := int64(binary.BigEndian.Uint32(header))
+		typ := string(header[4:])
+		if pos+12+length > size {
+			m.warn("Truncated PNG %s chunk", typ)
+			return nil
+		}
+		start := pos + 8
+		pos += 12 + length
+
+		switch typ {
+		case "IEND":
+			return nil
+		case "eXIf":
+			m.readPNGExif(r, start, length)
+			continue
+		case "IHDR", "iTXt", "tEXt", "zTXt", "pHYs", "iCCP":
+		default:
+			continue
+		}
+		if length > maxValueSize*16 {
+			m.warn("PNG %s chunk too large", typ)
+			continue
+		}
+		data := make([]byte, length)
+		if _, err := r.ReadAt(data, start); err != nil {
+			return err
+		}
+		var err error
+		switch typ {
+		case "IHDR":
+			m.readIHDR(data)
+		case "iTXt":
+			err = m.readITXt(data)
+		case "tEXt":
+			err = m.readTEXt(data, false)
+		case "zTXt":
+			err = m.readTEXt(data, true)
+		case "pHYs":
+			m.readPHYs(data)
+		case "iCCP":
+			err = m.readICCP(data)
+		}
+		if err != nil {
+			m.warn("Invalid PNG %s chunk: %v", typ, err)
+		}
+	}
+	return nil
+}
+
+// readPNGExif reads the eXIf chunk. Some writers keep the Exif header of the JPEG segment
+func (m *metadata) readPNGExif(r io.ReaderAt, start, length int64) {
+	header := make([]byte, len(exifHeader))
+	if _, err := r.ReadAt(header, start); err == nil && bytes.Equal(header, exifHeader) {
+		start, length = start+6, length-6
+	}
+	if err := m.readEXIF(r, start, length); err != nil {
+		m.warn("Malformed PNG eXIf chunk")
+	}
+}
+
+func (m *metadata) readIHDR(data []byte) {
+	if len(data) < 13 {
+		m.warn("Invalid PNG IHDR chunk")
+		return
+	}
+	m.set(groupImage, "ImageWidth", float64(binary.BigEndian.Uint32(data)))
+	m.set(groupImage, "ImageHeight", float64(binary.BigEndian.Uint32(data[4:])))
+	m.set(groupImage, "BitDepth", float64(data[8]))
+	if t, found := pngColorTypes[data[9]]; found {
+		m.set(groupImage, "ColorType", t)
+	} else {
+		m.set(groupImage, "ColorType", fmt.Sprintf("Unknown (%d)", data[9]))
+	}
+	if data[10] == 0 {
+		m.set(groupImage, "Compression", "Deflate/Inflate")
+	}
+	if data[11] == 0 {
+		m.set(groupImage, "Filter", "Adaptive")
+	}
+	interlace := map[byte]string{0: "Noninterlaced", 1: "Adam7 Interlace"}
+	if s, found := interlace[data[12]]; found {
+		m.set(groupImage, "Interlace", s)
+	}
+}
+
+// readITXt reads an international text chunk: keyword, compression flag and method,
+// language, translated keyword and the text, separated by NUL
+func (m *metadata) readITXt(data []byte) error {
+	parts := bytes.SplitN(data, []byte{0}, 2)
+	if len(parts) != 2 || len(parts[1]) < 2 {
+		return fmt.Errorf("missing keyword")
+	}
+	keyword := string(parts[0])
+	compressed := parts[1][0] == 1
+	rest := bytes.SplitN(parts[1][2:], []byte{0}, 3)
+	if len(rest) != 3 {
+		return fmt.Errorf("missing language")
+	}
+	text := rest[2]
+	if compressed {
+		zr, err := zlib.NewReader(bytes.NewReader(text))
+		if err != nil {
+			return err
+		}
+		if text, err = io.ReadAll(io.LimitReader(zr, maxValueSize*16)); err != nil {
+			return err
+		}
+	}
+	m.addPNGText(keyword, string(text))
+	return nil
+}
+
+// readTEXt reads a Latin-1 text chunk, zTXt chunks have a compression method byte and
+// compressed text
+func (m *metadata) readTEXt(data []byte, compressed bool) error {
+	keyword, text, found := bytes.Cut(data, []byte{0})
+	if !found {
+		return fmt.Errorf("missing keyword")
+	}
+	if compressed {
+		if len(text) < 1 {
+			return fmt.Errorf("missing compression method")
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(text[1:]))
+		if err != nil {
+			return err
+		}
+		if text, err = io.ReadAll(io.LimitReader(zr, maxValueSize*16)); err != nil {
+			return err
+		}
+	}
+	m.addPNGText(string(keyword), latin1(text))
+	return nil
+}
+
+// addPNGText adds a text chunk. XMP packets and the raw profiles of ImageMagick are decoded
+func (m *metadata) addPNGText(keyword string, text string) {
+	switch keyword {
+	case pngXMPKeyword:
+		if err := m.readXMP([]byte(text)); err != nil {
+			m.warn("Invalid XMP: %v", err)
+		}
+		return
+	case "Raw profile type exif", "Raw profile type APP1":
+		if data, err := rawProfile(text); err == nil && bytes.HasPrefix(data, exifHeader) {
+			data = data[len(exifHeader):]
+			if err := m.readEXIF(bytes.NewReader(data), 0, int64(len(data))); err != nil {
+				m.warn("Malformed PNG raw profile")
+			}
+		}
+		return
+	case "Raw profile type xmp":
+		if data, err := rawProfile(text); err == nil {
+			if err := m.readXMP(data); err != nil {
+				m.warn("Invalid XMP: %v", err)
+			}
+		}
+		return
+	}
+	tag, found := pngTextTags[keyword]
+	if !found {
+		tag.name, tag.group = pngTagName(keyword), groupImage
+		if tag.name == "" {
+			return
+		}
+	}
+	if tag.group == groupTime {
+		m.add(tag.group, tag.name, xmpConvert(xmpNamespace{}, tag.name, text))
+	} else {
+		m.add(tag.group, tag.name, value(text))
+	}
+}
+
+// pngTagName makes a tag name of a keyword as exiftool does by removing invalid characters
+// and capitalizing the first letter, e.g. "date:create" becomes Datecreate
+func pngTagName(keyword string) string {
+	var sb strings.Builder
+	for _, r := range keyword {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			if sb.Len() == 0 {
+				r = unicode.ToUpper(r)
+			}
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// rawProfile decodes the hex encoded profiles ImageMagick writes: a line with the profile
+// name, a line with the length and the hex data
+func rawProfile(text string) ([]byte, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("invalid raw profile")
+	}
+	return hex.DecodeString(strings.Join(fields[2:], ""))
+}
+
+// readPHYs reads the physical pixel dimensions
+func (m *metadata) readPHYs(data []byte) {
+	if len(data) < 9 {
+		m.warn("Invalid PNG pHYs chunk")
+		return
+	}
+	m.set(groupImage, "PixelsPerUnitX", float64(binary.BigEndian.Uint32(data)))
+	m.set(groupImage, "PixelsPerUnitY", float64(binary.BigEndian.Uint32(data[4:])))
+	if data[8] == 1 {
+		m.set(groupImage, "PixelUnits", "meters")
+	} else {
+		m.set(groupImage, "PixelUnits", "Unknown")
+	}
+}
+
+// readICCP reads the name and the description of the compressed ICC profile
+func (m *metadata) readICCP(data []byte) error {
+	name, profile, found := bytes.Cut(data, []byte{0})
+	if !found || len(profile) < 1 {
+		return fmt.Errorf("missing profile name")
+	}
+	m.set(groupImage, "ProfileName", latin1(name))
+	zr, err := zlib.NewReader(bytes.NewReader(profile[1:]))
+	if err != nil {
+		return err
+	}
+	if profile, err = io.ReadAll(io.LimitReader(zr, maxValueSize*16)); err != nil {
+		return err
+	}
+	m.readICCDescription(profile)
+	return nil
+}
+
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
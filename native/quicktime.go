@@ -0,0 +1,536 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// quickTimeBrands are the major brands of QuickTime and MP4 files by file type
+var quickTimeBrands = map[string]string{
+	"qt  ": "MOV",
+	"isom": "MP4", "iso2": "MP4", "iso4": "MP4", "iso5": "MP4", "iso6": "MP4", "mp41": "MP4",
+	"mp42": "MP4", "avc1": "MP4", "dash": "MP4", "MSNV": "MP4",
+	"M4V ": "M4V", "M4VH": "M4V", "M4VP": "M4V",
+	"3gp4": "3GP", "3gp5": "3GP", "3gp6": "3GP", "3gs7": "3GP",
+}
+
+// quickTimeEpoch is the time QuickTime dates count seconds from
+var quickTimeEpoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// isQuickTime returns a function that matches files with an ftyp box with a major brand of
+// fileType. Old QuickTime files have no ftyp box and start with one of the top level boxes
+func isQuickTime(fileType string) func(header []byte) bool {
+	return func(header []byte) bool {
+		if len(header) < 12 {
+			return false
+		}
+		switch string(header[4:8]) {
+		case "ftyp":
+			return quickTimeBrands[string(header[8:12])] == fileType
+		case "moov", "mdat", "wide", "free", "skip", "pnot":
+			return fileType == "MOV"
+		}
+		return false
+	}
+}
+
+// quickTimeTag names the metadata items of the udta and meta boxes
+type quickTimeTag struct {
+	name  string
+	group string
+}
+
+var quickTimeTags = map[string]quickTimeTag{
+	"com.apple.quicktime.make":             {"Make", groupCamera},
+	"com.apple.quicktime.model":            {"Model", groupCamera},
+	"com.apple.quicktime.software":         {"Software", groupImage},
+	"com.apple.quicktime.creationdate":     {"CreationDate", groupTime},
+	"com.apple.quicktime.location.ISO6709": {"GPSCoordinates", groupLocation},
+	"com.apple.quicktime.title":            {"Title", groupImage},
+	"\xa9xyz":                              {"GPSCoordinates", groupLocation},
+	"\xa9mak":                              {"Make", groupCamera},
+	"\xa9mod":                              {"Model", groupCamera},
+	"\xa9day":                              {"ContentCreateDate", groupTime},
+	"\xa9swr":                              {"SoftwareVersion", groupImage},
+	"\xa9nam":                              {"Title", groupImage},
+	"\xa9too":                              {"Encoder", groupOther},
+}
+
+// readQuickTime reads the movie header, the tracks and the metadata of the moov box
+func readQuickTime(m *metadata, r io.ReaderAt, size int64) error {
+	boxes, err := readBoxes(r, 0, size)
+	if err != nil && len(boxes) == 0 {
+		return err
+	}
+	moov, found := findBox(boxes, "moov")
+	if !found {
+		m.warn("No moov box found")
+		return nil
+	}
+	children, err := readBoxes(r, moov.start, moov.end())
+	if err != nil {
+		m.warn("Invalid moov box")
+	}
+	for _, b := range children {
+		switch b.typ {
+		case "mvhd":
+			if data, err := readPayload(r, b, maxValueSize); err == nil {
+				m.readMovieHeader(data)
+			}
+		case "trak":
+			m.readTrack(r, b)
+		case "udta":
+			m.readUserData(r, b)
+		case "meta":
+			m.readQuickTimeMeta(r, b)
+		}
+	}
+	return nil
+}
+
+// readMovieHeader reads the dates and duration of the movie
+func (m *metadata) readMovieHeader(data []byte) {
+	br := boxReader{data: data}
+	created, modified, scale, duration := br.times()
+	if br.err != nil {
+		m.warn("Invalid mvhd box")
+		return
+	}
+	m.add(groupTime, "CreateDate", quickTimeTime(created))
+	m.add(groupTime, "ModifyDate", quickTimeTime(modified))
+	m.add(groupVideo, "TimeScale", float64(scale))
+	if scale != 0 {
+		m.add(groupVideo, "Duration", quickTimeDuration(float64(duration)/float64(scale)))
+	}
+}
+
+// times reads the dates, time scale and duration of mvhd and mdhd boxes
+func (br *boxReader) times() (created, modified uint64, scale uint32, duration uint64) {
+	size := 4
+	if v, _ := br.fullBox(); v == 1 {
+		size = 8
+	}
+	created, modified = br.uint(size), br.uint(size)
+	scale = br.uint32()
+	duration = br.uint(size)
+	return
+}
+
+// track holds the boxes of a trak box that are used
+type track struct {
+	handler string
+	tkhd    []byte
+	mdhd    []byte
+	stsd    []byte
+	stts    []byte
+}
+
+// readTrack reads the header and sample description of a track. Video tracks give the image
+// size, rotation, codec and frame rate, sound tracks the audio format
+func (m *metadata) readTrack(r io.ReaderAt, trak box) {
+	var t track
+	var visit func(b box)
+	visit = func(b box) {
+		children, _ := readBoxes(r, b.start, b.end())
+		for _, c := range children {
+			switch c.typ {
+			case "mdia", "minf", "stbl":
+				visit(c)
+			case "tkhd", "mdhd", "hdlr", "stsd", "stts":
+				data, err := readPayload(r, c, maxValueSize)
+				if err != nil {
+					continue
+				}
+				switch c.typ {
+				case "tkhd":
+					t.tkhd = data
+				case "mdhd":
+					t.mdhd = data
+				case "hdlr":
+					if len(data) >= 12 {
+						t.handler = string(data[8:12])
+					}
+				case "stsd":
+					t.stsd = data
+				case "stts":
+					t.stts = data
+				}
+			}
+		}
+	}
+	visit(trak)
+
+	switch t.handler {
+	case "vide":
+		m.readTrackHeader(t.tkhd)
+		scale := m.readMediaHeader(t.mdhd)
+		m.readVideoSample(t.stsd)
+		if rate, ok := frameRate(t.stts, scale); ok {
+			m.add(groupVideo, "VideoFrameRate", value(formatNumber(math.Round(rate*1000)/1000)))
+		}
+	case "soun":
+		m.readAudioSample(t.stsd)
+	}
+}
+
+func (m *metadata) readTrackHeader(data []byte) {
+	if data == nil {
+		return
+	}
+	br := boxReader{data: data}
+	size := 4
+	if v, _ := br.fullBox(); v == 1 {
+		size = 8
+	}
+	created, modified := br.uint(size), br.uint(size)
+	//track id, reserved, duration, reserved, layer, alternate group and volume
+	br.next(8 + size + 16)
+	var matrix [9]int32
+	for i := range matrix {
+		matrix[i] = int32(br.uint32())
+	}
+	w, h := br.uint32(), br.uint32()
+	if br.err != nil {
+		m.warn("Invalid tkhd box")
+		return
+	}
+	m.add(groupTime, "TrackCreateDate", quickTimeTime(created))
+	m.add(groupTime, "TrackModifyDate", quickTimeTime(modified))
+	m.add(groupVideo, "ImageWidth", float64(w)/65536)
+	m.add(groupVideo, "ImageHeight", float64(h)/65536)
+	rotation := math.Atan2(float64(matrix[1]), float64(matrix[0])) * 180 / math.Pi
+	m.add(groupVideo, "Rotation", math.Mod(math.Round(rotation)+360, 360))
+}
+
+// readMediaHeader reads the media dates and returns the time scale of the samples
+func (m *metadata) readMediaHeader(data []byte) uint32 {
+	if data == nil {
+		return 0
+	}
+	br := boxReader{data: data}
+	created, modified, scale, duration := br.times()
+	if br.err != nil {
+		m.warn("Invalid mdhd box")
+		return 0
+	}
+	m.add(groupTime, "MediaCreateDate", quickTimeTime(created))
+	m.add(groupTime, "MediaModifyDate", quickTimeTime(modified))
+	m.add(groupVideo, "MediaTimeScale", float64(scale))
+	if scale != 0 {
+		m.add(groupVideo, "MediaDuration", quickTimeDuration(float64(duration)/float64(scale)))
+	}
+	return scale
+}
+
+// readVideoSample reads the first entry of the sample description of a video track
+func (m *metadata) readVideoSample(data []byte) {
+	br := boxReader{data: data}
+	br.fullBox()
+	if br.uint32() == 0 {
+		return
+	}
+	br.uint32()
+	codec := string(br.next(4))
+	br.next(6 + 2 + 16)
+	w, h := br.uint16(), br.uint16()
+	xres, yres := br.uint32(), br.uint32()
+	br.next(4 + 2)
+	name := br.next(32)
+	depth := br.uint16()
+	if br.err != nil {
+		m.warn("Invalid stsd box")
+		return
+	}
+	m.add(groupVideo, "CompressorID", codec)
+	m.add(groupVideo, "SourceImageWidth", float64(w))
+	m.add(groupVideo, "SourceImageHeight", float64(h))
+	m.add(groupVideo, "XResolution", float64(xres)/65536)
+	m.add(groupVideo, "YResolution", float64(yres)/65536)
+	if n := int(name[0]); n > 0 && n < 32 {
+		m.add(groupVideo, "CompressorName", string(name[1:1+n]))
+	}
+	m.add(groupVideo, "BitDepth", float64(depth))
+}
+
+// readAudioSample reads the first entry of the sample description of a sound track
+func (m *metadata) readAudioSample(data []byte) {
+	br := boxReader{data: data}
+	br.fullBox()
+	if br.uint32() == 0 {
+		return
+	}
+	br.uint32()
+	codec := string(br.next(4))
+	br.next(6 + 2 + 8)
+	channels, bits := br.uint16(), br.uint16()
+	br.next(4)
+	rate := br.uint32()
+	if br.err != nil {
+		m.warn("Invalid stsd box")
+		return
+	}
+	m.add(groupAudio, "AudioFormat", codec)
+	m.add(groupAudio, "AudioChannels", float64(channels))
+	m.add(groupAudio, "AudioBitsPerSample", float64(bits))
+	m.add(groupAudio, "AudioSampleRate", float64(rate)/65536)
+}
+
+// frameRate computes the frame rate from the sample durations of the stts box
+func frameRate(data []byte, scale uint32) (float64, bool) {
+	br := boxReader{data: data}
+	br.fullBox()
+	count := br.uint32()
+	var samples, duration uint64
+	for i := uint32(0); i < count && br.err == nil; i++ {
+		n, d := br.uint32(), br.uint32()
+		samples += uint64(n)
+		duration += uint64(n) * uint64(d)
+	}
+	if br.err != nil || duration == 0 || scale == 0 {
+		return 0, false
+	}
+	return float64(scale) * float64(samples) / float64(duration), true
+}
+
+// readUserData reads the text items of the udta box and its meta box
+func (m *metadata) readUserData(r io.ReaderAt, udta box) {
+	children, _ := readBoxes(r, udta.start, udta.end())
+	for _, b := range children {
+		if b.typ == "meta" {
+			m.readQuickTimeMeta(r, b)
+			continue
+		}
+		tag, found := quickTimeTags[b.typ]
+		if !found {
+			continue
+		}
+		data, err := readPayload(r, b, maxValueSize)
+		if err != nil || len(data) < 4 {
+			continue
+		}
+		//a text item is a list of strings with a size and a language code
+		size := int(binary.BigEndian.Uint16(data))
+		if 4+size > len(data) {
+			continue
+		}
+		m.addQuickTimeTag(tag, string(data[4:4+size]))
+	}
+}
+
+// readQuickTimeMeta reads the items of a meta box. Items in the ilst box are either named by
+// the index of a key in the keys box or by the type of the item
+func (m *metadata) readQuickTimeMeta(r io.ReaderAt, meta box) {
+	data, err := readPayload(r, meta, maxValueSize*16)
+	if err != nil {
+		m.warn("Invalid meta box")
+		return
+	}
+	//the meta box is a full box in MP4 files but not in QuickTime files
+	start := int64(0)
+	if len(data) >= 8 && string(data[4:8]) != "hdlr" {
+		start = 4
+	}
+	boxes, _ := readBoxes(bytes.NewReader(data), start, int64(len(data)))
+	var keys []string
+	if b, found := findBox(boxes, "keys"); found {
+		br := boxReader{data: data[b.start:b.end()]}
+		br.fullBox()
+		count := br.uint32()
+		for i := uint32(0); i < count && br.err == nil; i++ {
+			size := int(br.uint32())
+			br.next(4)
+			keys = append(keys, string(br.next(size-8)))
+		}
+	}
+	ilst, found := findBox(boxes, "ilst")
+	if !found {
+		return
+	}
+	items, _ := readBoxes(bytes.NewReader(data), ilst.start, ilst.end())
+	for _, item := range items {
+		name := item.typ
+		if index := int(binary.BigEndian.Uint32([]byte(item.typ))); index > 0 && index <= len(keys) {
+			name = keys[index-1]
+		}
+		tag, found := quickTimeTags[name]
+		if !found {
+			if !strings.HasPrefix(name, "com.apple.quicktime.") {
+				continue
+			}
+			tag = quickTimeTag{keyName(strings.TrimPrefix(name, "com.apple.quicktime.")), groupOther}
+		}
+		values, _ := readBoxes(bytes.NewReader(data), item.start, item.end())
+		if b, found := findBox(values, "data"); found && b.size >= 8 {
+			m.addQuickTimeTag(tag, dataValue(data[b.start:b.end()]))
+		}
+	}
+}
+
+// dataValue converts the value of a data box with one of the well-known types
+func dataValue(data []byte) interface{} {
+	typ, v := binary.BigEndian.Uint32(data)&0xffffff, data[8:]
+	switch {
+	case typ == 1:
+		return string(v)
+	case typ == 23 && len(v) == 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(v)))
+	case typ == 24 && len(v) == 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(v))
+	case typ == 21 || typ == 22:
+		var n int64
+		for i, b := range v {
+			if i == 0 && typ == 21 {
+				n = int64(int8(b))
+			} else {
+				n = n<<8 | int64(b)
+			}
+		}
+		return float64(n)
+	}
+	return binaryData(int64(len(v)))
+}
+
+// keyName converts a key like location.accuracy.horizontal to LocationAccuracyHorizontal
+func keyName(key string) string {
+	var sb strings.Builder
+	for _, part := range strings.FieldsFunc(key, func(r rune) bool { return r == '.' || r == '-' }) {
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
+
+func (m *metadata) addQuickTimeTag(tag quickTimeTag, v interface{}) {
+	s, isString := v.(string)
+	switch {
+	case !isString:
+		m.add(tag.group, tag.name, v)
+	case tag.name == "GPSCoordinates":
+		m.addISO6709(s)
+	case tag.group == groupTime:
+		m.add(tag.group, tag.name, quickTimeDate(s))
+	default:
+		m.add(tag.group, tag.name, value(s))
+	}
+}
+
+var iso6709 = regexp.MustCompile(`^([+-][\d.]+)([+-][\d.]+)([+-][\d.]+)?`)
+
+// addISO6709 adds the GPS coordinates of an ISO 6709 location, e.g. +59.3290+018.0687+025.0/.
+// The coordinates are also added as GPSLatitude, GPSLongitude and GPSAltitude as exiftool
+// does
+func (m *metadata) addISO6709(s string) {
+	parts := iso6709.FindStringSubmatch(s)
+	if parts == nil {
+		m.add(groupLocation, "GPSCoordinates", s)
+		return
+	}
+	lat, err1 := iso6709Degrees(parts[1], 2)
+	lon, err2 := iso6709Degrees(parts[2], 3)
+	if err1 != nil || err2 != nil {
+		m.add(groupLocation, "GPSCoordinates", s)
+		return
+	}
+	hemisphere := func(c float64, positive, negative string) string {
+		if c < 0 {
+			return dms(c) + " " + negative
+		}
+		return dms(c) + " " + positive
+	}
+	latitude, longitude := hemisphere(lat, "N", "S"), hemisphere(lon, "E", "W")
+	coordinates := latitude + ", " + longitude
+	m.add(groupLocation, "GPSLatitude", latitude)
+	m.add(groupLocation, "GPSLongitude", longitude)
+	if alt, err := strconv.ParseFloat(parts[3], 64); err == nil {
+		ref := "Above Sea Level"
+		if alt < 0 {
+			ref = "Below Sea Level"
+		}
+		coordinates += fmt.Sprintf(", %s m %s", formatNumber(math.Abs(alt)), ref)
+		m.add(groupLocation, "GPSAltitude", math.Abs(alt))
+		m.add(groupLocation, "GPSAltitudeRef", ref)
+	}
+	m.add(groupLocation, "GPSCoordinates", coordinates)
+}
+
+// iso6709Degrees parses ±DD.DD, ±DDMM.MM or ±DDMMSS.SS where degrees has the given number
+// of digits
+func iso6709Degrees(s string, digits int) (float64, error) {
+	sign := 1.0
+	if s[0] == '-' {
+		sign = -1
+	}
+	s = s[1:]
+	integer := strings.IndexByte(s, '.')
+	if integer < 0 {
+		integer = len(s)
+	}
+	var d, min, sec float64
+	var err error
+	switch integer - digits {
+	case 0:
+		d, err = strconv.ParseFloat(s, 64)
+	case 2:
+		if d, err = strconv.ParseFloat(s[:digits], 64); err == nil {
+			min, err = strconv.ParseFloat(s[digits:], 64)
+		}
+	case 4:
+		if d, err = strconv.ParseFloat(s[:digits], 64); err == nil {
+			if min, err = strconv.ParseFloat(s[digits:digits+2], 64); err == nil {
+				sec, err = strconv.ParseFloat(s[digits+2:], 64)
+			}
+		}
+	default:
+		err = fmt.Errorf("invalid ISO 6709 coordinate %s", s)
+	}
+	return sign * (d + min/60 + sec/3600), err
+}
+
+var timeZoneOffset = regexp.MustCompile(`([-+]\d{2})(\d{2})$`)
+
+// quickTimeDate converts an ISO 8601 date like 2021-06-01T12:20:30+0200 the way exiftool does
+func quickTimeDate(s string) interface{} {
+	v := xmpConvert(xmpNamespace{}, "Date", s)
+	if str, ok := v.(string); ok {
+		return timeZoneOffset.ReplaceAllString(str, "$1:$2")
+	}
+	return v
+}
+
+// quickTimeTime prints seconds since 1904 in UTC
+func quickTimeTime(secs uint64) string {
+	if secs == 0 || secs > math.MaxInt64 {
+		return "0000:00:00 00:00:00"
+	}
+	return quickTimeEpoch.Add(time.Duration(secs) * time.Second).Format("2006:01:02 15:04:05")
+}
+
+// quickTimeDuration prints a duration in seconds as exiftool does, e.g. 5.97 s or 0:01:05
+func quickTimeDuration(secs float64) string {
+	if secs == 0 {
+		return "0 s"
+	}
+	sign := ""
+	if secs < 0 {
+		sign, secs = "-", -secs
+	}
+	if secs < 30 {
+		return fmt.Sprintf("%s%.2f s", sign, secs)
+	}
+	secs += 0.5
+	h := int(secs / 3600)
+	secs -= float64(h * 3600)
+	min := int(secs / 60)
+	secs -= float64(min * 60)
+	if h > 24 {
+		sign = fmt.Sprintf("%s%d days ", sign, h/24)
+		h %= 24
+	}
+	return fmt.Sprintf("%s%d:%02d:%02d", sign, h, min, int(secs))
+}
@@ -0,0 +1,68 @@
+package native
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+var rafHeader = []byte("FUJIFILMCCD-RAW ")
+
+// rafTags are the tags of the RAF directory. The sizes are stored as height and width
+var rafTags = map[uint16]string{
+	0x0100: "RawImageFullSize",
+	0x0111: "RawImageCroppedSize",
+}
+
+// readRAF reads a Fujifilm RAF file. The header locates an embedded JPEG with the EXIF data
+// and a directory with the size of the raw image
+func readRAF(m *metadata, r io.ReaderAt, size int64) error {
+	header := make([]byte, 0x64)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return err
+	}
+	jpegOffset := int64(binary.BigEndian.Uint32(header[0x54:]))
+	jpegLength := int64(binary.BigEndian.Uint32(header[0x58:]))
+	if jpegLength > 0 && jpegOffset+jpegLength <= size {
+		if err := m.readJPEGAt(r, jpegOffset, jpegOffset+jpegLength); err != nil {
+			m.warn("Malformed RAF JPEG image")
+		}
+		if isPreviewJPEG(r, jpegOffset, jpegLength) {
+			m.previews = append(m.previews, preview{offset: jpegOffset, length: jpegLength})
+		}
+	} else {
+		m.warn("Invalid RAF JPEG image")
+	}
+
+	dirOffset := int64(binary.BigEndian.Uint32(header[0x5c:]))
+	dirLength := int64(binary.BigEndian.Uint32(header[0x60:]))
+	if dirLength < 4 || dirLength > maxValueSize || dirOffset+dirLength > size {
+		return nil
+	}
+	dir := make([]byte, dirLength)
+	if _, err := r.ReadAt(dir, dirOffset); err != nil {
+		return err
+	}
+	m.readRAFDirectory(dir)
+	return nil
+}
+
+// readRAFDirectory reads the entries of the RAF directory. Each entry is a tag and the size
+// of its value
+func (m *metadata) readRAFDirectory(dir []byte) {
+	count := binary.BigEndian.Uint32(dir)
+	pos := 4
+	for i := uint32(0); i < count && pos+4 <= len(dir); i++ {
+		tag := binary.BigEndian.Uint16(dir[pos:])
+		length := int(binary.BigEndian.Uint16(dir[pos+2:]))
+		pos += 4
+		if pos+length > len(dir) {
+			return
+		}
+		if name, found := rafTags[tag]; found && length == 4 {
+			height, width := binary.BigEndian.Uint16(dir[pos:]), binary.BigEndian.Uint16(dir[pos+2:])
+			m.set(groupImage, name, fmt.Sprintf("%dx%d", width, height))
+		}
+		pos += length
+	}
+}
@@ -0,0 +1,126 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// rawFormats are the TIFF based raw formats by the upper case prefix of Make. They are told
+// apart from TIFF files by the raw image data
+var rawFormats = map[string]format{
+	"NIKON": {fileType: "NEF", extension: "nef", mimeType: "image/x-nikon-nef"},
+	"SONY":  {fileType: "ARW", extension: "arw", mimeType: "image/x-sony-arw"},
+}
+
+var dngFormat = format{fileType: "DNG", extension: "dng", mimeType: "image/x-adobe-dng"}
+
+// preview is an embedded JPEG image. offset is the offset in the file
+type preview struct {
+	offset int64
+	length int64
+}
+
+// isCR2 matches a TIFF header followed by the CR2 signature
+func isCR2(header []byte) bool {
+	return len(header) >= 12 && isTIFF(header) && string(header[8:10]) == "CR"
+}
+
+// setRawFormat replaces the TIFF file type of DNG files and raw files of known cameras
+func (m *metadata) setRawFormat() {
+	if _, found := m.get(groupImage, "DNGVersion"); found {
+		m.setFormat(dngFormat)
+		return
+	}
+	photometric, _ := m.get(groupImage, "PhotometricInterpretation")
+	if photometric != "Color Filter Array" && photometric != "Linear Raw" {
+		return
+	}
+	camera, _ := m.get(groupCamera, "Make")
+	for prefix, f := range rawFormats {
+		if strings.HasPrefix(strings.ToUpper(text(camera)), prefix) {
+			m.setFormat(f)
+			return
+		}
+	}
+}
+
+// subfileType returns the NewSubfileType of an IFD, 0 for the full resolution image and -1
+// if it is not set
+func subfileType(entries []*entry) int {
+	if e := findEntry(entries, tagSubfileType); e != nil && e.len() > 0 {
+		return int(e.number(0))
+	}
+	return -1
+}
+
+func findEntry(entries []*entry, tag uint16) *entry {
+	for _, e := range entries {
+		if e.tag == tag {
+			return e
+		}
+	}
+	return nil
+}
+
+// addPreviewIFD adds the JPEG image of an IFD to the previews. It is either located by the
+// JPEG offset and length tags or stored as a single JPEG compressed strip
+func (m *metadata) addPreviewIFD(t *tiff, entries []*entry) {
+	var offset, length *entry
+	if offset, length = findEntry(entries, tagJPEGOffset), findEntry(entries, tagJPEGLength); offset == nil || length == nil {
+		compression := findEntry(entries, tagCompression)
+		if compression == nil || compression.number(0) != 6 && compression.number(0) != 7 {
+			return
+		}
+		offset, length = findEntry(entries, tagStripOffsets), findEntry(entries, tagStripByteCounts)
+		if offset == nil || length == nil || offset.len() != 1 || length.len() != 1 {
+			return
+		}
+	}
+	if offset.len() == 0 || length.len() == 0 {
+		return
+	}
+	o, l := int64(offset.number(0)), int64(length.number(0))
+	if l > 0 && o+l <= t.r.Size() && isPreviewJPEG(t.r, o, l) {
+		m.previews = append(m.previews, preview{offset: t.base + o, length: l})
+	}
+}
+
+// isPreviewJPEG checks that the JPEG at offset is a baseline or progressive JPEG. The raw
+// image data of DNG and CR2 files is stored as a lossless JPEG
+func isPreviewJPEG(r io.ReaderAt, offset, length int64) bool {
+	header := make([]byte, 4)
+	if _, err := r.ReadAt(header[:2], offset); err != nil || !bytes.Equal(header[:2], []byte{0xff, 0xd8}) {
+		return false
+	}
+	for pos, end := offset+2, offset+length; pos+4 <= end; {
+		if _, err := r.ReadAt(header, pos); err != nil || header[0] != 0xff {
+			return false
+		}
+		switch marker := header[1]; {
+		case marker == 0xc0 || marker == 0xc1 || marker == 0xc2:
+			return true
+		case encodingProcesses[marker] != "" || marker == 0xd9 || marker == 0xda:
+			return false
+		}
+		pos += 2 + int64(binary.BigEndian.Uint16(header[2:]))
+	}
+	return false
+}
+
+// addPreview reports the largest preview like exiftool reports the PreviewImage of raw files
+func (m *metadata) addPreview() {
+	var largest preview
+	for _, p := range m.previews {
+		if p.length > largest.length {
+			largest = p
+		}
+	}
+	if largest.length == 0 {
+		return
+	}
+	m.set(groupImage, "PreviewImageStart", float64(largest.offset))
+	m.set(groupImage, "PreviewImageLength", float64(largest.length))
+	m.set(groupPreview, "PreviewImage", binaryData(largest.length))
+}
@@ -0,0 +1,387 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// TIFF field types
+const (
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeSByte     = 6
+	typeUndefined = 7
+	typeSShort    = 8
+	typeSLong     = 9
+	typeSRational = 10
+	typeFloat     = 11
+	typeDouble    = 12
+	typeIFD       = 13
+)
+
+var typeSizes = [...]int64{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8, 4}
+
+// IFD pointer tags
+const (
+	tagExifIFD    = 0x8769
+	tagGPSIFD     = 0x8825
+	tagInteropIFD = 0xa005
+	tagSubIFDs    = 0x014a
+)
+
+// tags that locate the image data of an IFD
+const (
+	tagSubfileType     = 0x00fe
+	tagCompression     = 0x0103
+	tagStripOffsets    = 0x0111
+	tagStripByteCounts = 0x0117
+	tagJPEGOffset      = 0x0201
+	tagJPEGLength      = 0x0202
+)
+
+const maxEntries = 1000
+const maxValueSize = 1 << 20
+
+var errBadTIFF = errors.New("bad TIFF header")
+
+// tiff reads the IFDs of a TIFF structure, either a TIFF file or the EXIF block of another
+// format. Offsets are relative to the TIFF header at base.
+type tiff struct {
+	r       *io.SectionReader
+	base    int64
+	order   binary.ByteOrder
+	visited map[int64]bool
+}
+
+func isTIFF(header []byte) bool {
+	return bytes.HasPrefix(header, []byte("II*\x00")) || bytes.HasPrefix(header, []byte("MM\x00*"))
+}
+
+// newTIFF reads the TIFF header at base and returns the offset of IFD0
+func newTIFF(r io.ReaderAt, base int64, size int64) (*tiff, int64, error) {
+	t := tiff{r: io.NewSectionReader(r, base, size), base: base, visited: map[int64]bool{}}
+	header := make([]byte, 8)
+	if _, err := t.r.ReadAt(header, 0); err != nil {
+		return nil, 0, errBadTIFF
+	}
+	switch string(header[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, 0, errBadTIFF
+	}
+	if t.order.Uint16(header[2:]) != 42 {
+		return nil, 0, errBadTIFF
+	}
+	return &t, int64(t.order.Uint32(header[4:])), nil
+}
+
+func (t *tiff) byteOrder() string {
+	if t.order == binary.LittleEndian {
+		return "Little-endian (Intel, II)"
+	}
+	return "Big-endian (Motorola, MM)"
+}
+
+// entry is a single IFD entry. Values larger than maxValueSize are not loaded
+type entry struct {
+	tag    uint16
+	typ    uint16
+	count  int64
+	offset int64
+	data   []byte
+	order  binary.ByteOrder
+}
+
+// readIFD reads the entries of the IFD at offset and returns them with the offset of the next
+// IFD, 0 if there is none
+func (t *tiff) readIFD(offset int64) ([]*entry, int64, error) {
+	if offset < 8 || t.visited[offset] {
+		return nil, 0, fmt.Errorf("bad IFD offset %d", offset)
+	}
+	t.visited[offset] = true
+	b := make([]byte, 2)
+	if _, err := t.r.ReadAt(b, offset); err != nil {
+		return nil, 0, err
+	}
+	n := int64(t.order.Uint16(b))
+	if n == 0 || n > maxEntries {
+		return nil, 0, fmt.Errorf("bad IFD entry count %d", n)
+	}
+	b = make([]byte, n*12+4)
+	read, err := t.r.ReadAt(b, offset+2)
+	if int64(read) < n*12 {
+		return nil, 0, err
+	}
+	var next int64
+	if int64(read) == n*12+4 {
+		next = int64(t.order.Uint32(b[n*12:]))
+	}
+
+	entries := make([]*entry, 0, n)
+	for i := int64(0); i < n; i++ {
+		raw := b[i*12 : i*12+12]
+		e := entry{
+			tag:   t.order.Uint16(raw),
+			typ:   t.order.Uint16(raw[2:]),
+			count: int64(t.order.Uint32(raw[4:])),
+			order: t.order,
+		}
+		if e.typ == 0 || int(e.typ) >= len(typeSizes) {
+			continue
+		}
+		size := typeSizes[e.typ] * e.count
+		if size <= 4 {
+			e.offset = offset + 2 + i*12 + 8
+			e.data = raw[8 : 8+size]
+		} else {
+			e.offset = int64(t.order.Uint32(raw[8:]))
+			if e.offset+size > t.r.Size() {
+				continue
+			}
+			if size <= maxValueSize {
+				e.data = make([]byte, size)
+				if _, err := t.r.ReadAt(e.data, e.offset); err != nil {
+					continue
+				}
+			}
+		}
+		entries = append(entries, &e)
+	}
+	return entries, next, nil
+}
+
+func (e *entry) len() int {
+	if e.data == nil {
+		return 0
+	}
+	return int(e.count)
+}
+
+// rational returns the numerator and denominator of value i of a rational entry
+func (e *entry) rational(i int) (float64, float64) {
+	b := e.data[i*8:]
+	if e.typ == typeSRational {
+		return float64(int32(e.order.Uint32(b))), float64(int32(e.order.Uint32(b[4:])))
+	}
+	return float64(e.order.Uint32(b)), float64(e.order.Uint32(b[4:]))
+}
+
+// number returns value i of a numeric entry as a float. Rationals with a zero denominator
+// are returned as NaN
+func (e *entry) number(i int) float64 {
+	if i >= e.len() {
+		return math.NaN()
+	}
+	switch e.typ {
+	case typeByte, typeUndefined, typeASCII:
+		return float64(e.data[i])
+	case typeSByte:
+		return float64(int8(e.data[i]))
+	case typeShort:
+		return float64(e.order.Uint16(e.data[i*2:]))
+	case typeSShort:
+		return float64(int16(e.order.Uint16(e.data[i*2:])))
+	case typeLong, typeIFD:
+		return float64(e.order.Uint32(e.data[i*4:]))
+	case typeSLong:
+		return float64(int32(e.order.Uint32(e.data[i*4:])))
+	case typeRational, typeSRational:
+		num, den := e.rational(i)
+		if den == 0 {
+			return math.NaN()
+		}
+		return num / den
+	case typeFloat:
+		return float64(math.Float32frombits(e.order.Uint32(e.data[i*4:])))
+	case typeDouble:
+		return math.Float64frombits(e.order.Uint64(e.data[i*8:]))
+	}
+	return math.NaN()
+}
+
+func (e *entry) numbers() []float64 {
+	ret := make([]float64, e.len())
+	for i := range ret {
+		ret[i] = e.number(i)
+	}
+	return ret
+}
+
+// str returns the entry as a string, cut at the first NUL
+func (e *entry) str() string {
+	s := string(e.data)
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// formatted returns the values of the entry as exiftool prints them without a conversion,
+// numbers separated by space
+func (e *entry) formatted() string {
+	if e.typ == typeASCII {
+		return e.str()
+	}
+	values := make([]string, e.len())
+	for i := range values {
+		values[i] = e.formatValue(i)
+	}
+	return strings.Join(values, " ")
+}
+
+func (e *entry) formatValue(i int) string {
+	if e.typ == typeRational || e.typ == typeSRational {
+		if num, den := e.rational(i); den == 0 {
+			if num == 0 {
+				return "undef"
+			}
+			return "inf"
+		}
+	}
+	return formatNumber(e.number(i))
+}
+
+// value is the default conversion of an entry
+func (e *entry) value() interface{} {
+	if e.data == nil || e.typ == typeUndefined {
+		return binaryData(e.count * typeSizes[e.typ])
+	}
+	return value(e.formatted())
+}
+
+func binaryData(size int64) string {
+	return fmt.Sprintf("(Binary data %d bytes, use -b option to extract)", size)
+}
+
+func readTIFF(m *metadata, r io.ReaderAt, size int64) error {
+	if err := m.readEXIF(r, 0, size); err != nil {
+		return err
+	}
+	m.setRawFormat()
+	return nil
+}
+
+// readEXIF reads the IFDs of the TIFF structure at base. Only a bad header is an error, bad
+// directories are reported as warnings
+func (m *metadata) readEXIF(r io.ReaderAt, base int64, size int64) error {
+	t, ifd0, err := newTIFF(r, base, size)
+	if err != nil {
+		return err
+	}
+	m.set(groupOther, "ExifByteOrder", t.byteOrder())
+	next, err := m.readDir(t, ifd0, "IFD0", exifTags, false)
+	if err != nil {
+		m.warn("Bad IFD0 directory")
+		return nil
+	}
+	if next != 0 {
+		if _, err := m.readDir(t, next, "IFD1", exifTags, true); err != nil {
+			m.warn("Bad IFD1 directory")
+		}
+	}
+	return nil
+}
+
+// readDir reads the IFD at offset with the given tag table. Tags of low priority directories
+// do not replace existing values
+func (m *metadata) readDir(t *tiff, offset int64, name string, tags map[uint16]tagInfo, low bool) (int64, error) {
+	entries, next, err := t.readIFD(offset)
+	if err != nil {
+		return 0, err
+	}
+	//the full resolution image of raw files is in a SubIFD and has priority over IFD0
+	if strings.HasPrefix(name, "SubIFD") {
+		low = subfileType(entries) != 0
+	}
+	for _, e := range entries {
+		if sub, table := subIFD(e.tag, name); table != nil {
+			for i := 0; i < e.len(); i++ {
+				dir := sub
+				if i > 0 {
+					dir = fmt.Sprintf("%s%d", sub, i)
+				}
+				if _, err := m.readDir(t, int64(e.number(i)), dir, table, low); err != nil {
+					m.warn("Bad %s directory", dir)
+				}
+			}
+			continue
+		}
+		switch {
+		case e.tag == tagMakerNote && name == "ExifIFD":
+			m.readMakerNote(t, e)
+			continue
+		case (e.tag == tagJPEGOffset || e.tag == tagJPEGLength) && name != "IFD1":
+			//only IFD1 holds the thumbnail, other directories hold previews
+			continue
+		}
+		if info, found := tags[e.tag]; found {
+			m.addEntry(t, e, info, low)
+		}
+	}
+	if name == "IFD1" {
+		m.addThumbnail(t)
+	} else {
+		m.addPreviewIFD(t, entries)
+	}
+	return next, nil
+}
+
+// addEntry adds the printed value of an entry
+func (m *metadata) addEntry(t *tiff, e *entry, info tagInfo, low bool) {
+	var v interface{}
+	if info.print != nil && e.data != nil {
+		v = info.print(e)
+	} else {
+		v = e.value()
+	}
+	if info.offset {
+		v = float64(t.base) + e.number(0)
+	}
+	if v == nil {
+		return
+	}
+	if low {
+		m.add(info.group, info.name, v)
+	} else {
+		m.set(info.group, info.name, v)
+	}
+}
+
+func subIFD(tag uint16, dir string) (string, map[uint16]tagInfo) {
+	switch {
+	case tag == tagExifIFD && (dir == "IFD0" || dir == "IFD1"):
+		return "ExifIFD", exifTags
+	case tag == tagGPSIFD && (dir == "IFD0" || dir == "IFD1"):
+		return "GPS", gpsTags
+	case tag == tagInteropIFD && dir == "ExifIFD":
+		return "InteropIFD", interopTags
+	case tag == tagSubIFDs && dir == "IFD0":
+		return "SubIFD", exifTags
+	}
+	return "", nil
+}
+
+// addThumbnail reports the thumbnail of IFD1 if it is inside the TIFF structure
+func (m *metadata) addThumbnail(t *tiff) {
+	offset, ok1 := m.get(groupImage, "ThumbnailOffset")
+	length, ok2 := m.get(groupImage, "ThumbnailLength")
+	if !ok1 || !ok2 {
+		return
+	}
+	o, ok1 := offset.(float64)
+	l, ok2 := length.(float64)
+	if ok1 && ok2 && l > 0 && int64(o)-t.base+int64(l) <= t.r.Size() {
+		m.add(groupPreview, "ThumbnailImage", binaryData(int64(l)))
+	}
+}
@@ -0,0 +1,97 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+func isWebP(header []byte) bool {
+	return len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == "WEBP"
+}
+
+// readWebP reads the chunks of a RIFF WebP file. The image size is read from the VP8X
+// chunk of extended files or from the VP8 or VP8L bitstream of simple files
+func readWebP(m *metadata, r io.ReaderAt, size int64) error {
+	riff := make([]byte, 4)
+	if _, err := r.ReadAt(riff, 4); err != nil {
+		return err
+	}
+	if end := 8 + int64(binary.LittleEndian.Uint32(riff)); end < size {
+		size = end
+	}
+	pos := int64(12)
+	header := make([]byte, 8)
+	for pos+8 <= size {
+		if _, err := r.ReadAt(header, pos); err != nil {
+			return err
+		}
+		typ := string(header[:4])
+		length := int64(binary.LittleEndian.Uint32(header[4:]))
+		if pos+8+length > size {
+			m.warn("Truncated WebP %s chunk", typ)
+			return nil
+		}
+		start := pos + 8
+		//chunks are padded to an even size
+		pos += 8 + length + length&1
+
+		switch typ {
+		case "EXIF":
+			m.readWebPExif(r, start, length)
+			continue
+		case "VP8X", "VP8 ", "VP8L", "XMP ", "ICCP":
+		default:
+			continue
+		}
+		if length > maxValueSize*16 {
+			m.warn("WebP %s chunk too large", typ)
+			continue
+		}
+		data := make([]byte, length)
+		if _, err := r.ReadAt(data, start); err != nil {
+			return err
+		}
+		switch typ {
+		case "VP8X":
+			if len(data) >= 10 {
+				m.set(groupImage, "ImageWidth", float64(uint24(data[4:])+1))
+				m.set(groupImage, "ImageHeight", float64(uint24(data[7:])+1))
+			}
+		case "VP8 ":
+			//a key frame starts with a 3 byte frame tag and the start code
+			if len(data) >= 10 && bytes.Equal(data[3:6], []byte{0x9d, 0x01, 0x2a}) {
+				m.add(groupImage, "ImageWidth", float64(binary.LittleEndian.Uint16(data[6:])&0x3fff))
+				m.add(groupImage, "ImageHeight", float64(binary.LittleEndian.Uint16(data[8:])&0x3fff))
+			}
+		case "VP8L":
+			if len(data) >= 5 && data[0] == 0x2f {
+				bits := binary.LittleEndian.Uint32(data[1:])
+				m.add(groupImage, "ImageWidth", float64(bits&0x3fff+1))
+				m.add(groupImage, "ImageHeight", float64(bits>>14&0x3fff+1))
+			}
+		case "XMP ":
+			if err := m.readXMP(data); err != nil {
+				m.warn("Invalid XMP: %v", err)
+			}
+		case "ICCP":
+			m.readICCDescription(data)
+		}
+	}
+	return nil
+}
+
+// readWebPExif reads the EXIF chunk. As in PNG files it may start with the Exif header
+func (m *metadata) readWebPExif(r io.ReaderAt, start, length int64) {
+	header := make([]byte, len(exifHeader))
+	if _, err := r.ReadAt(header, start); err == nil && bytes.Equal(header, exifHeader) {
+		start, length = start+6, length-6
+	}
+	if err := m.readEXIF(r, start, length); err != nil {
+		m.warn("Malformed WebP EXIF chunk")
+	}
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
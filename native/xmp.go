@@ -0,0 +1,411 @@
+package native
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const xmpHeader = "http://ns.adobe.com/xap/1.0/\x00"
+
+const (
+	rdfNS  = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmlNS  = "http://www.w3.org/XML/1998/namespace"
+	metaNS = "adobe:ns:meta/"
+)
+
+// xmpNamespace describes how the properties of a namespace are named and grouped
+type xmpNamespace struct {
+	//group is the default group of the properties
+	group  string
+	groups map[string]string
+	//names are tag names that differ from the property name. An empty name removes the
+	//level from the names of flattened structures
+	names map[string]string
+	//exif namespaces use the names, groups and conversions of the EXIF tags
+	exif bool
+}
+
+var xmpNamespaces = map[string]xmpNamespace{
+	"http://purl.org/dc/elements/1.1/": {group: groupOther, groups: map[string]string{
+		"contributor": groupAuthor, "creator": groupAuthor, "publisher": groupAuthor, "rights": groupAuthor,
+		"date": groupTime, "description": groupImage, "title": groupImage,
+	}},
+	"http://ns.adobe.com/xap/1.0/": {group: groupImage, groups: map[string]string{
+		"CreateDate": groupTime, "MetadataDate": groupTime, "ModifyDate": groupTime,
+	}},
+	"http://ns.adobe.com/xap/1.0/mm/":     {group: groupOther},
+	"http://ns.adobe.com/xap/1.0/rights/": {group: groupAuthor},
+	"http://ns.adobe.com/photoshop/1.0/": {group: groupImage, groups: map[string]string{
+		"AuthorsPosition": groupAuthor, "CaptionWriter": groupAuthor, "City": groupLocation,
+		"Country": groupLocation, "Credit": groupAuthor, "DateCreated": groupTime, "Source": groupAuthor,
+		"State": groupLocation,
+	}},
+	"http://iptc.org/std/Iptc4xmpCore/1.0/xmlns/": {group: groupOther, groups: map[string]string{
+		"CountryCode": groupLocation, "CreatorContactInfo": groupAuthor, "Location": groupLocation,
+	}, names: map[string]string{
+		"CreatorContactInfo": "Creator", "CiAdrCity": "City", "CiAdrCtry": "Country",
+		"CiAdrExtadr": "Address", "CiAdrPcode": "PostalCode", "CiAdrRegion": "Region",
+		"CiEmailWork": "WorkEmail", "CiTelWork": "WorkTelephone", "CiUrlWork": "WorkURL",
+	}},
+	"http://iptc.org/std/Iptc4xmpExt/2008-02-29/": {group: groupOther, groups: map[string]string{
+		"LocationCreated": groupLocation, "LocationShown": groupLocation,
+	}},
+	"http://ns.adobe.com/lightroom/1.0/":           {group: groupOther},
+	"http://ns.adobe.com/camera-raw-settings/1.0/": {group: groupImage},
+	"http://ns.adobe.com/exif/1.0/": {group: groupImage, exif: true, names: map[string]string{
+		"DateTimeDigitized": "CreateDate", "ISOSpeedRatings": "ISO",
+	}},
+	"http://cipa.jp/exif/1.0/": {group: groupImage, exif: true},
+	"http://ns.adobe.com/tiff/1.0/": {group: groupImage, exif: true, names: map[string]string{
+		"DateTime": "ModifyDate",
+	}},
+	"http://ns.adobe.com/exif/1.0/aux/": {group: groupCamera, exif: true},
+	"http://www.metadataworkinggroup.com/schemas/regions/": {group: groupImage, names: map[string]string{
+		"Regions": "Region", "RegionList": "",
+	}},
+}
+
+// exifTagsByName finds the EXIF tag of XMP properties in the exif namespaces
+var exifTagsByName = map[string]tagInfo{}
+
+func init() {
+	for _, table := range []map[uint16]tagInfo{exifTags, gpsTags} {
+		for _, info := range table {
+			exifTagsByName[info.name] = info
+		}
+	}
+}
+
+type xmlNode struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*xmlNode
+	text     string
+}
+
+func parseXML(data []byte) (*xmlNode, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return root, nil
+		} else if err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name, attrs: t.Attr}
+			top.children = append(top.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			top.text += string(t)
+		}
+	}
+}
+
+func (n *xmlNode) attr(space, local string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func (n *xmlNode) is(space, local string) bool {
+	return n.name.Space == space && n.name.Local == local
+}
+
+// xmpValue is a simple value, a list (rdf:Bag or rdf:Seq), a language alternative (rdf:Alt)
+// or a structure
+type xmpValue struct {
+	text   string
+	lang   string
+	list   []*xmpValue
+	alt    []*xmpValue
+	fields []xmpProperty
+}
+
+type xmpProperty struct {
+	ns    string
+	name  string
+	value *xmpValue
+}
+
+// properties returns the properties of a node, both the ones written as attributes and as
+// child elements
+func properties(n *xmlNode) []xmpProperty {
+	var ret []xmpProperty
+	for _, a := range n.attrs {
+		if a.Name.Space == rdfNS || a.Name.Space == xmlNS || a.Name.Space == "xmlns" || a.Name.Space == "" {
+			continue
+		}
+		ret = append(ret, xmpProperty{a.Name.Space, a.Name.Local, &xmpValue{text: a.Value}})
+	}
+	for _, c := range n.children {
+		ret = append(ret, xmpProperty{c.name.Space, c.name.Local, parseXMPValue(c)})
+	}
+	return ret
+}
+
+func parseXMPValue(n *xmlNode) *xmpValue {
+	v := xmpValue{}
+	v.lang, _ = n.attr(xmlNS, "lang")
+	if r, found := n.attr(rdfNS, "resource"); found {
+		v.text = r
+		return &v
+	}
+	if pt, _ := n.attr(rdfNS, "parseType"); pt == "Resource" {
+		v.fields = properties(n)
+		return &v
+	}
+	for _, c := range n.children {
+		switch {
+		case c.is(rdfNS, "Bag"), c.is(rdfNS, "Seq"):
+			for _, li := range c.children {
+				if li.is(rdfNS, "li") {
+					v.list = append(v.list, parseXMPValue(li))
+				}
+			}
+			return &v
+		case c.is(rdfNS, "Alt"):
+			for _, li := range c.children {
+				if li.is(rdfNS, "li") {
+					v.alt = append(v.alt, parseXMPValue(li))
+				}
+			}
+			return &v
+		case c.is(rdfNS, "Description"):
+			v.fields = properties(c)
+			return &v
+		}
+	}
+	if v.fields = properties(n); len(v.fields) > 0 {
+		return &v
+	}
+	v.text = n.text
+	return &v
+}
+
+// readXMP reads an XMP packet. XMP has the lowest priority and does not replace tags that
+// are already set from EXIF or IPTC
+func (m *metadata) readXMP(data []byte) error {
+	root, err := parseXML(data)
+	if err != nil {
+		return err
+	}
+	var visit func(n *xmlNode)
+	visit = func(n *xmlNode) {
+		if n.is(metaNS, "xmpmeta") {
+			if tk, found := n.attr(metaNS, "xmptk"); found {
+				m.add(groupOther, "XMPToolkit", tk)
+			}
+		}
+		if n.is(rdfNS, "RDF") {
+			for _, d := range n.children {
+				if d.is(rdfNS, "Description") {
+					for _, p := range properties(d) {
+						m.addXMPProperty(p)
+					}
+				}
+			}
+			return
+		}
+		for _, c := range n.children {
+			visit(c)
+		}
+	}
+	visit(root)
+	return nil
+}
+
+// xmpTags collects the flattened values of a property in order
+type xmpTags struct {
+	names  []string
+	values map[string][]string
+}
+
+func (t *xmpTags) add(name string, value string) {
+	if _, found := t.values[name]; !found {
+		t.names = append(t.names, name)
+	}
+	t.values[name] = append(t.values[name], value)
+}
+
+func (m *metadata) addXMPProperty(p xmpProperty) {
+	ns, found := xmpNamespaces[p.ns]
+	if !found {
+		ns = xmpNamespace{group: groupOther}
+	}
+	name := xmpName(p.ns, p.name)
+	group := ns.group
+	if g, found := ns.groups[p.name]; found {
+		group = g
+	} else if info, found := exifTagsByName[name]; found && ns.exif {
+		group = info.group
+	}
+
+	tags := xmpTags{values: map[string][]string{}}
+	flattenXMP(name, p.value, &tags)
+	for _, n := range tags.names {
+		values := tags.values[n]
+		if len(values) == 1 {
+			m.add(group, n, xmpConvert(ns, n, values[0]))
+			continue
+		}
+		list := make([]interface{}, len(values))
+		for i, v := range values {
+			list[i] = xmpConvert(ns, n, v)
+		}
+		m.add(group, n, list)
+	}
+}
+
+// flattenXMP names the values of structures by joining the names of the structure and its
+// fields as exiftool does, e.g. HistoryAction for the action field of the History structure
+func flattenXMP(name string, v *xmpValue, tags *xmpTags) {
+	switch {
+	case v.alt != nil:
+		for _, item := range v.alt {
+			if item.lang == "" || item.lang == "x-default" {
+				flattenXMP(name, item, tags)
+			} else {
+				flattenXMP(name+"-"+item.lang, item, tags)
+			}
+		}
+	case v.list != nil:
+		for _, item := range v.list {
+			flattenXMP(name, item, tags)
+		}
+	case v.fields != nil:
+		for _, f := range v.fields {
+			flattenXMP(name+xmpName(f.ns, f.name), f.value, tags)
+		}
+	default:
+		tags.add(name, v.text)
+	}
+}
+
+func xmpName(ns string, property string) string {
+	if name, found := xmpNamespaces[ns].names[property]; found {
+		return name
+	}
+	r := []rune(property)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+var xmpDateTime = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})T(\d{2}):(\d{2})(:\d{2})?(\S*)$`)
+var xmpDate = regexp.MustCompile(`^\d{4}(-\d{2}){0,2}$`)
+var xmpCoordinate = regexp.MustCompile(`^(\d+),(\d+(?:\.\d+)?)(?:,(\d+(?:\.\d+)?))?([NSEW])$`)
+var xmpRational = regexp.MustCompile(`^-?\d+/\d+$`)
+
+// xmpConvert converts a value the way exiftool prints it. Dates are printed in the EXIF
+// format and properties of the exif namespaces are printed as the EXIF tags
+func xmpConvert(ns xmpNamespace, name string, s string) interface{} {
+	if m := xmpDateTime.FindStringSubmatch(s); m != nil {
+		return fmt.Sprintf("%s:%s:%s %s:%s%s%s", m[1], m[2], m[3], m[4], m[5], m[6], m[7])
+	}
+	if (strings.Contains(name, "Date") || strings.HasSuffix(name, "When")) && xmpDate.MatchString(s) {
+		return strings.ReplaceAll(s, "-", ":")
+	}
+	if !ns.exif {
+		return value(s)
+	}
+	if m := xmpCoordinate.FindStringSubmatch(s); m != nil {
+		d, _ := strconv.ParseFloat(m[1], 64)
+		min, _ := strconv.ParseFloat(m[2], 64)
+		sec, _ := strconv.ParseFloat(m[3], 64)
+		return dms(d+min/60+sec/3600) + " " + m[4]
+	}
+	if info, found := exifTagsByName[name]; found && info.print != nil {
+		if e := xmpEntry(s); e != nil {
+			return info.print(e)
+		}
+	}
+	if e := xmpEntry(s); e != nil && e.typ == typeSRational {
+		return value(e.formatted())
+	}
+	return value(s)
+}
+
+// xmpEntry converts a numeric XMP value, e.g. 28/10 or "2800/100 2800/100 392/256 761/256",
+// to an entry so the EXIF conversions can be used. It returns nil for other values
+func xmpEntry(s string) *entry {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil
+	}
+	rational := make([]byte, 0, 8*len(fields))
+	double := make([]byte, 0, 8*len(fields))
+	isRational := true
+	for _, f := range fields {
+		var d float64
+		num, den := int64(0), int64(1)
+		if xmpRational.MatchString(f) {
+			parts := strings.SplitN(f, "/", 2)
+			n, err1 := strconv.ParseInt(parts[0], 10, 32)
+			dd, err2 := strconv.ParseInt(parts[1], 10, 32)
+			if err1 != nil || err2 != nil {
+				return nil
+			}
+			num, den = n, dd
+			d = math.NaN()
+			if den != 0 {
+				d = float64(num) / float64(den)
+			}
+		} else if jsonNumber.MatchString(f) {
+			d, _ = strconv.ParseFloat(f, 64)
+			if n, err := strconv.ParseInt(f, 10, 32); err == nil {
+				num = n
+			} else {
+				isRational = false
+			}
+		} else {
+			return nil
+		}
+		rational = binary.BigEndian.AppendUint32(rational, uint32(int32(num)))
+		rational = binary.BigEndian.AppendUint32(rational, uint32(int32(den)))
+		double = binary.BigEndian.AppendUint64(double, math.Float64bits(d))
+	}
+	if isRational {
+		return &entry{typ: typeSRational, count: int64(len(fields)), data: rational, order: binary.BigEndian}
+	}
+	return &entry{typ: typeDouble, count: int64(len(fields)), data: double, order: binary.BigEndian}
+}
+
+func isXMP(header []byte) bool {
+	h := bytes.TrimLeft(bytes.TrimPrefix(header, []byte("\xef\xbb\xbf")), " \t\r\n")
+	return bytes.HasPrefix(h, []byte("<?xpacket")) || bytes.HasPrefix(h, []byte("<x:xmpmeta")) ||
+		bytes.HasPrefix(h, []byte("<rdf:RDF")) || bytes.HasPrefix(h, []byte("<?xml"))
+}
+
+// readXMPFile reads a sidecar file
+func readXMPFile(m *metadata, r io.ReaderAt, size int64) error {
+	if size > maxValueSize*16 {
+		return fmt.Errorf("XMP file too large")
+	}
+	data := make([]byte, size)
+	if _, err := r.ReadAt(data, 0); err != nil && err != io.EOF {
+		return err
+	}
+	if err := m.readXMP(data); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package mexif
+
+import (
+	"errors"
+	"fmt"
+	"github.com/msvens/mexif/native"
+	"io/fs"
+)
+
+// NativeReader reads metadata with the pure Go parsers of the native package instead of
+// exiftool. It supports fewer formats and tags but returns the same ExifData layout, so it
+// can be used where exiftool is not installed
+type NativeReader struct{}
+
+func NewNativeReader() *NativeReader {
+	return &NativeReader{}
+}
+
+func (nr *NativeReader) ExifData(path string) (*ExifData, error) {
+	root, err := native.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %w", ErrFileNotFound, err)
+	} else if errors.Is(err, fs.ErrPermission) {
+		return nil, fmt.Errorf("%w: %w", ErrFileUnreadable, err)
+	} else if err != nil {
+		return nil, err
+	}
+	return newExifData(path, root, nil)
+}
+
+func (nr *NativeReader) ExifCompact(path string) (*ExifCompact, error) {
+	data, err := nr.ExifData(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewExifCompact(data), nil
+}
+
+// Close does nothing, it is there so NativeReader can be used like MExifTool
+func (nr *NativeReader) Close() error {
+	return nil
+}
@@ -0,0 +1,268 @@
+package mexif
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultPoolIdleTimeout = 30 * time.Second
+
+// MExifToolPool keeps between minSize and maxSize stay open exiftool processes and hands each
+// request to an idle one, so concurrent callers are not serialized on a single process. It
+// exposes the same read methods as MExifTool.
+type MExifToolPool struct {
+	mutex       sync.Mutex
+	flags       []string
+	minSize     int
+	idleTimeout time.Duration
+	timeout     int64
+	idle        chan *pooledTool
+	slots       chan struct{}
+	done        chan struct{}
+	closed      bool
+}
+
+type pooledTool struct {
+	tool     *MExifTool
+	lastUsed time.Time
+}
+
+// NewMExifToolPool starts minSize exiftool processes and allows the pool to grow to maxSize
+// processes under load. Processes above minSize that have been idle for longer than
+// DefaultPoolIdleTimeout are closed. flags are passed to every process just as in NewMExifTool.
+func NewMExifToolPool(minSize, maxSize int, flags ...string) (*MExifToolPool, error) {
+	return NewMExifToolPoolWithTimeout(minSize, maxSize, DefaultPoolIdleTimeout, flags...)
+}
+
+// NewMExifToolPoolWithTimeout is like NewMExifToolPool but with a custom idle timeout. An
+// idleTimeout <= 0 disables shrinking.
+func NewMExifToolPoolWithTimeout(minSize, maxSize int, idleTimeout time.Duration, flags ...string) (*MExifToolPool, error) {
+	if maxSize < 1 || minSize < 0 || minSize > maxSize {
+		return nil, fmt.Errorf("invalid pool size min: %d max: %d", minSize, maxSize)
+	}
+	pool := MExifToolPool{
+		flags:       flags,
+		minSize:     minSize,
+		idleTimeout: idleTimeout,
+		idle:        make(chan *pooledTool, maxSize),
+		slots:       make(chan struct{}, maxSize),
+		done:        make(chan struct{}),
+	}
+	for i := 0; i < minSize; i++ {
+		pool.slots <- struct{}{}
+		tool, err := NewMExifTool(flags...)
+		if err != nil {
+			<-pool.slots
+			_ = pool.Close()
+			return nil, err
+		}
+		pool.idle <- &pooledTool{tool: tool, lastUsed: time.Now()}
+	}
+	if idleTimeout > 0 {
+		go pool.shrink()
+	}
+	return &pool, nil
+}
+
+// Size returns the number of exiftool processes currently owned by the pool
+func (pool *MExifToolPool) Size() int {
+	return len(pool.slots)
+}
+
+// SetTimeout sets the timeout used by the methods that do not take a context, see MExifTool.SetTimeout
+func (pool *MExifToolPool) SetTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&pool.timeout, int64(timeout))
+}
+
+func (pool *MExifToolPool) Close() error {
+	pool.mutex.Lock()
+	if pool.closed {
+		pool.mutex.Unlock()
+		return nil
+	}
+	pool.closed = true
+	close(pool.done)
+	pool.mutex.Unlock()
+
+	var errs []error
+	for {
+		select {
+		case pt := <-pool.idle:
+			if err := pool.discard(pt); err != nil {
+				errs = append(errs, err)
+			}
+		default:
+			if len(errs) > 0 {
+				return fmt.Errorf("error while closing pool: %v", errs)
+			}
+			return nil
+		}
+	}
+}
+
+func (pool *MExifToolPool) ExifCompact(path string) (*ExifCompact, error) {
+	ctx, cancel := timeoutContext(atomic.LoadInt64(&pool.timeout))
+	defer cancel()
+	return pool.ExifCompactContext(ctx, path)
+}
+
+func (pool *MExifToolPool) ExifCompactContext(ctx context.Context, path string) (*ExifCompact, error) {
+	if d, err := pool.ExifDataContext(ctx, path); err == nil {
+		return NewExifCompact(d), nil
+	} else {
+		return nil, err
+	}
+}
+
+func (pool *MExifToolPool) ExifData(path string) (*ExifData, error) {
+	ctx, cancel := timeoutContext(atomic.LoadInt64(&pool.timeout))
+	defer cancel()
+	return pool.ExifDataContext(ctx, path)
+}
+
+func (pool *MExifToolPool) ExifDataContext(ctx context.Context, path string) (*ExifData, error) {
+	resp, err := pool.readResponse(ctx, jsonOutput, path)
+	if err != nil {
+		return nil, err
+	}
+	return resp.exifData(path)
+}
+
+func (pool *MExifToolPool) Unmarshal(path string) (map[string]interface{}, error) {
+	ctx, cancel := timeoutContext(atomic.LoadInt64(&pool.timeout))
+	defer cancel()
+	return pool.UnmarshalContext(ctx, path)
+}
+
+func (pool *MExifToolPool) UnmarshalContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	resp, err := pool.readResponse(ctx, jsonOutput, path)
+	if err != nil {
+		return nil, err
+	}
+	return resp.first()
+}
+
+func (pool *MExifToolPool) Read(path string) ([]byte, error) {
+	return pool.ReadWithFlags(path)
+}
+
+func (pool *MExifToolPool) ReadWithFlags(path string, flags ...string) ([]byte, error) {
+	ctx, cancel := timeoutContext(atomic.LoadInt64(&pool.timeout))
+	defer cancel()
+	return pool.ReadContext(ctx, path, flags...)
+}
+
+func (pool *MExifToolPool) ReadContext(ctx context.Context, path string, flags ...string) ([]byte, error) {
+	resp, err := pool.readResponse(ctx, rawOutput, path, flags...)
+	if err != nil {
+		return nil, err
+	}
+	return resp.stdout, nil
+}
+
+func (pool *MExifToolPool) readResponse(ctx context.Context, mode outputMode, path string, flags ...string) (*response, error) {
+	resp, err := pool.readArgs(ctx, mode, jsonArgs([]string{path}, flags))
+	if err != nil {
+		return nil, err
+	}
+	return resp, resp.err(path)
+}
+
+func (pool *MExifToolPool) readArgs(ctx context.Context, mode outputMode, args []string) (*response, error) {
+	pt, err := pool.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := pt.tool.readArgs(ctx, mode, args)
+	if errors.Is(err, ErrClosed) {
+		//the tool has given up restarting exiftool
+		_ = pool.discard(pt)
+		return nil, err
+	}
+	pt.lastUsed = time.Now()
+	pool.release(pt)
+	return resp, err
+}
+
+func (pool *MExifToolPool) acquire(ctx context.Context) (*pooledTool, error) {
+	if pool.isClosed() {
+		return nil, fmt.Errorf("MExifToolPool is closed")
+	}
+	//prefer an idle process before starting a new one
+	select {
+	case pt := <-pool.idle:
+		return pt, nil
+	default:
+	}
+	select {
+	case pt := <-pool.idle:
+		return pt, nil
+	case pool.slots <- struct{}{}:
+		tool, err := NewMExifTool(pool.flags...)
+		if err != nil {
+			<-pool.slots
+			return nil, err
+		}
+		return &pooledTool{tool: tool}, nil
+	case <-pool.done:
+		return nil, fmt.Errorf("MExifToolPool is closed")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (pool *MExifToolPool) release(pt *pooledTool) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	if pool.closed {
+		_ = pool.discard(pt)
+		return
+	}
+	pool.idle <- pt
+}
+
+func (pool *MExifToolPool) discard(pt *pooledTool) error {
+	defer func() { <-pool.slots }()
+	return pt.tool.Close()
+}
+
+func (pool *MExifToolPool) isClosed() bool {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	return pool.closed
+}
+
+func (pool *MExifToolPool) shrink() {
+	ticker := time.NewTicker(pool.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pool.done:
+			return
+		case <-ticker.C:
+			pool.closeIdle()
+		}
+	}
+}
+
+func (pool *MExifToolPool) closeIdle() {
+	var keep []*pooledTool
+	for n := len(pool.idle); n > 0; n-- {
+		select {
+		case pt := <-pool.idle:
+			if len(pool.slots) > pool.minSize && time.Since(pt.lastUsed) > pool.idleTimeout {
+				_ = pool.discard(pt)
+			} else {
+				keep = append(keep, pt)
+			}
+		default:
+		}
+	}
+	for _, pt := range keep {
+		pool.release(pt)
+	}
+}
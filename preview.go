@@ -0,0 +1,150 @@
+package mexif
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+)
+
+const BinaryArg = "-b"
+
+// binaryEnd is echoed after binary output so that the ready token starts on a new line
+const binaryEnd = "{binary}"
+
+var ErrNoPreview = errors.New("no preview image")
+
+// PreviewKind selects one of the embedded images of the Preview group
+type PreviewKind int
+
+const (
+	//LargestPreview is the largest of the other kinds found in the file
+	LargestPreview PreviewKind = iota
+	ThumbnailImage
+	PreviewImage
+	JpgFromRaw
+)
+
+var previewTags = map[PreviewKind]string{
+	ThumbnailImage: "ThumbnailImage",
+	PreviewImage:   "PreviewImage",
+	JpgFromRaw:     "JpgFromRaw",
+}
+
+// exiftool prints binary tags as (Binary data 19152 bytes, use -b option to extract)
+var binarySize = regexp.MustCompile(`^\(Binary data (\d+) bytes`)
+
+func (k PreviewKind) String() string {
+	if tag, found := previewTags[k]; found {
+		return tag
+	}
+	return "LargestPreview"
+}
+
+// Preview returns the embedded image of the given kind as it is stored in the file, usually a
+// JPEG. ErrNoPreview is returned if the file has no such image
+func (tool *MExifTool) Preview(path string, which PreviewKind) ([]byte, error) {
+	ctx, cancel := tool.context()
+	defer cancel()
+	return tool.PreviewContext(ctx, path, which)
+}
+
+func (tool *MExifTool) PreviewContext(ctx context.Context, path string, which PreviewKind) ([]byte, error) {
+	return readPreview(ctx, tool.readArgs, path, which)
+}
+
+// DecodePreview decodes the largest embedded image, e.g. to show raw files without decoding
+// the raw image data
+func (tool *MExifTool) DecodePreview(path string) (image.Image, error) {
+	b, err := tool.Preview(path, LargestPreview)
+	if err != nil {
+		return nil, err
+	}
+	return decodePreview(b)
+}
+
+func (pool *MExifToolPool) Preview(path string, which PreviewKind) ([]byte, error) {
+	ctx, cancel := timeoutContext(atomic.LoadInt64(&pool.timeout))
+	defer cancel()
+	return pool.PreviewContext(ctx, path, which)
+}
+
+func (pool *MExifToolPool) PreviewContext(ctx context.Context, path string, which PreviewKind) ([]byte, error) {
+	return readPreview(ctx, pool.readArgs, path, which)
+}
+
+func (pool *MExifToolPool) DecodePreview(path string) (image.Image, error) {
+	b, err := pool.Preview(path, LargestPreview)
+	if err != nil {
+		return nil, err
+	}
+	return decodePreview(b)
+}
+
+func readPreview(ctx context.Context, read argsReader, path string, which PreviewKind) ([]byte, error) {
+	if which == LargestPreview {
+		var err error
+		if which, err = largestPreview(ctx, read, path); err != nil {
+			return nil, err
+		}
+	}
+	tag, found := previewTags[which]
+	if !found {
+		return nil, fmt.Errorf("unknown preview kind %d", which)
+	}
+	resp, err := read(ctx, rawOutput, []string{BinaryArg, "-" + tag, "-echo3", binaryEnd, path})
+	if err != nil {
+		return nil, err
+	}
+	b := bytes.TrimSuffix(resp.stdout, []byte("\n"))
+	b = bytes.TrimSuffix(bytes.TrimSuffix(b, []byte("\r")), []byte(binaryEnd))
+	if len(b) == 0 {
+		if errs := resp.messages(errorPrefix); len(errs) > 0 {
+			return nil, newExifToolError(path, errs[0])
+		}
+		return nil, fmt.Errorf("%w: %s - %s", ErrNoPreview, tag, path)
+	}
+	return b, nil
+}
+
+// largestPreview reads the sizes of the images in the Preview group and returns the kind of
+// the largest one
+func largestPreview(ctx context.Context, read argsReader, path string) (PreviewKind, error) {
+	resp, err := read(ctx, jsonOutput, jsonArgs([]string{path}, []string{"-Preview:all"}))
+	if err != nil {
+		return LargestPreview, err
+	}
+	if err := resp.err(path); err != nil {
+		return LargestPreview, err
+	}
+	data, err := resp.exifData(path)
+	if err != nil {
+		return LargestPreview, err
+	}
+	largest, size := LargestPreview, 0
+	for kind, tag := range previewTags {
+		v, _ := data.Preview[tag].(string)
+		match := binarySize.FindStringSubmatch(v)
+		if match == nil {
+			continue
+		}
+		//ties go to the larger kind to make the choice deterministic
+		if n, err := strconv.Atoi(match[1]); err == nil && (n > size || n == size && kind > largest) {
+			largest, size = kind, n
+		}
+	}
+	if largest == LargestPreview {
+		return largest, fmt.Errorf("%w: %s", ErrNoPreview, path)
+	}
+	return largest, nil
+}
+
+func decodePreview(b []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(b))
+	return img, err
+}
@@ -0,0 +1,255 @@
+package mexif
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultMaxRestarts = 3
+const DefaultRestartBackoff = 100 * time.Millisecond
+
+const maxRestartBackoff = 10 * time.Second
+const stderrTailSize = 4096
+const maxStderrSize = 1024 * 1024
+const stopTimeout = 5 * time.Second
+
+var ErrProcessExited = errors.New("exiftool process exited")
+
+// ProcessExitedError is returned when the exiftool process dies. It matches ErrProcessExited
+// with errors.Is.
+type ProcessExitedError struct {
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *ProcessExitedError) Error() string {
+	msg := fmt.Sprintf("exiftool exited with status %d", e.ExitCode)
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+func (e *ProcessExitedError) Is(target error) bool {
+	return target == ErrProcessExited
+}
+
+func (e *ProcessExitedError) Unwrap() error {
+	return e.Err
+}
+
+// process is one running exiftool child. A supervising goroutine waits for the child and
+// closes exited once it has been reaped. Stderr is split on the ready token that each request
+// echoes with -echo4 and delivered on errout, the tail of it is also kept for crash reports.
+type process struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  *bufio.Reader
+	errout  chan []byte
+	stderr  *tailBuffer
+	exited  chan struct{}
+	waitErr error
+}
+
+func startProcess(args []string) (*process, error) {
+	cmd := exec.Command(Cmd, args...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	//use our own pipe for stderr so that cmd.Wait does not close it before everything is read
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = stderrW
+
+	proc := process{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+		errout: make(chan []byte, 1),
+		stderr: &tailBuffer{size: stderrTailSize},
+		exited: make(chan struct{}),
+	}
+
+	err = cmd.Start()
+	_ = stderrW.Close()
+	if err != nil {
+		_ = stderrR.Close()
+		return nil, err
+	}
+
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		defer stderrR.Close()
+		stderr := bufio.NewReader(io.TeeReader(stderrR, proc.stderr))
+		for {
+			var b bytes.Buffer
+			//only keep the first maxStderrSize bytes but always read up to the ready token
+			rr := newReadyReader(stderr)
+			_, _ = io.Copy(&b, io.LimitReader(rr, maxStderrSize))
+			if _, err := io.Copy(io.Discard, rr); err != nil {
+				return
+			}
+			select {
+			case proc.errout <- b.Bytes():
+			case <-proc.exited:
+				return
+			}
+		}
+	}()
+	go func() {
+		proc.waitErr = cmd.Wait()
+		select {
+		case <-stderrDone:
+		case <-time.After(time.Second):
+		}
+		close(proc.exited)
+	}()
+	return &proc, nil
+}
+
+func (proc *process) hasExited() bool {
+	select {
+	case <-proc.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// exitError describes how the process exited. Must only be called after exited is closed
+func (proc *process) exitError() *ProcessExitedError {
+	return &ProcessExitedError{
+		ExitCode: proc.cmd.ProcessState.ExitCode(),
+		Stderr:   strings.TrimSpace(proc.stderr.String()),
+		Err:      proc.waitErr,
+	}
+}
+
+func (proc *process) kill() {
+	_ = proc.cmd.Process.Kill()
+	<-proc.exited
+}
+
+// stop asks exiftool to exit and kills it if it has not done so within stopTimeout
+func (proc *process) stop() error {
+	if proc.hasExited() {
+		return nil
+	}
+	//Execute stay open false
+	fmt.Fprintln(proc.stdin, StayOpenArg)
+	fmt.Fprintln(proc.stdin, "False")
+	fmt.Fprintln(proc.stdin, ExecuteArg)
+
+	var err error
+	if e := proc.stdin.Close(); e != nil {
+		err = fmt.Errorf("error while closing stdin: %w", e)
+	}
+
+	select {
+	case <-proc.exited:
+	case <-time.After(stopTimeout):
+		proc.kill()
+		err = fmt.Errorf("exiftool did not exit within %v and was killed", stopTimeout)
+	}
+	return err
+}
+
+// readyReader reads a single response from exiftool. It returns io.EOF once the ready token
+// that terminates the response has been read, leaving r positioned at the next response. An
+// error is returned if the stream ends before the ready token.
+type readyReader struct {
+	r       *bufio.Reader
+	pending []byte
+	bol     bool
+	done    bool
+}
+
+func newReadyReader(r *bufio.Reader) *readyReader {
+	return &readyReader{r: r, bol: true}
+}
+
+func (rr *readyReader) Read(p []byte) (int, error) {
+	if rr.done {
+		return 0, io.EOF
+	}
+	for len(rr.pending) == 0 {
+		line, err := rr.r.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			if len(line) == 0 {
+				return 0, err
+			}
+		}
+		if rr.bol && isReadyToken(line) {
+			rr.done = true
+			return 0, io.EOF
+		}
+		rr.bol = bytes.HasSuffix(line, []byte("\n"))
+		rr.pending = line
+	}
+	n := copy(p, rr.pending)
+	rr.pending = rr.pending[n:]
+	return n, nil
+}
+
+func isReadyToken(line []byte) bool {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	//windows
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	return string(line) == readyToken
+}
+
+// tailBuffer is an io.Writer that keeps the last size bytes written to it
+type tailBuffer struct {
+	mutex sync.Mutex
+	size  int
+	buf   []byte
+}
+
+func (tb *tailBuffer) Write(p []byte) (int, error) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	tb.buf = append(tb.buf, p...)
+	if len(tb.buf) > tb.size {
+		tb.buf = tb.buf[len(tb.buf)-tb.size:]
+	}
+	return len(p), nil
+}
+
+func (tb *tailBuffer) String() string {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	return string(tb.buf)
+}
+
+func restartBackoff(backoff time.Duration, restarts int) time.Duration {
+	for i := 1; i < restarts && backoff < maxRestartBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRestartBackoff {
+		return maxRestartBackoff
+	}
+	return backoff
+}
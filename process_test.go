@@ -0,0 +1,57 @@
+package mexif
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadyReader(t *testing.T) {
+	big := strings.Repeat("x", 200000)
+	stream := "[{\"a\":\"" + big + "\"}]\n{ready}\nsecond\r\n{ready}\r\nnot {ready}\n{ready}\n"
+	r := bufio.NewReader(strings.NewReader(stream))
+
+	expected := []string{"[{\"a\":\"" + big + "\"}]\n", "second\r\n", "not {ready}\n"}
+	for _, e := range expected {
+		b, err := io.ReadAll(newReadyReader(r))
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if !bytes.Equal(b, []byte(e)) {
+			t.Errorf("expected %d bytes got %d", len(e), len(b))
+		}
+	}
+	if _, err := io.ReadAll(newReadyReader(r)); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF got %v", err)
+	}
+}
+
+func TestRestartBackoff(t *testing.T) {
+	tests := []struct {
+		backoff  time.Duration
+		restarts int
+		expected time.Duration
+	}{
+		{100 * time.Millisecond, 0, 100 * time.Millisecond},
+		{100 * time.Millisecond, 1, 100 * time.Millisecond},
+		{100 * time.Millisecond, 2, 200 * time.Millisecond},
+		{100 * time.Millisecond, 4, 800 * time.Millisecond},
+		{100 * time.Millisecond, 8, maxRestartBackoff},
+		{100 * time.Millisecond, 1000, maxRestartBackoff},
+		{time.Minute, 1, maxRestartBackoff},
+	}
+	for _, test := range tests {
+		if d := restartBackoff(test.backoff, test.restarts); d != test.expected {
+			t.Errorf("expected %v for %v and %d restarts got %v", test.expected, test.backoff, test.restarts, d)
+		}
+	}
+	//the delay never shrinks as restarts grow
+	for i := 1; i < 100; i++ {
+		if restartBackoff(time.Millisecond, i+1) < restartBackoff(time.Millisecond, i) {
+			t.Errorf("expected backoff to grow at %d restarts", i)
+		}
+	}
+}
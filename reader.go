@@ -0,0 +1,131 @@
+package mexif
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Reader is a backend that reads metadata from files. It is implemented by MExifTool,
+// MExifToolPool, NativeReader and CompositeReader
+type Reader interface {
+	ExifData(path string) (*ExifData, error)
+	ExifCompact(path string) (*ExifCompact, error)
+	Close() error
+}
+
+var _ Reader = (*MExifTool)(nil)
+var _ Reader = (*MExifToolPool)(nil)
+var _ Reader = (*NativeReader)(nil)
+var _ Reader = (*CompositeReader)(nil)
+
+// ReadReport tells which backend of a CompositeReader answered a call
+type ReadReport struct {
+	Backend string
+	//PrimaryErr is the error of the primary backend if it could not read the file
+	PrimaryErr error
+	//Missing are the required fields the primary backend did not find
+	Missing []string
+	//FallbackErr is the error of the fallback backend if the data of the primary backend
+	//was returned because the fallback failed
+	FallbackErr error
+}
+
+// CompositeReader reads files with a primary backend, typically a NativeReader, and falls
+// back to a second one, typically MExifTool, if the primary one fails or does not find all
+// required fields
+type CompositeReader struct {
+	primary  Reader
+	fallback Reader
+	required []string
+}
+
+// NewCompositeReader creates a reader that uses fallback when primary fails or when any of
+// the required ExifCompact fields, e.g. "CameraMake" or "OriginalDate", is missing
+func NewCompositeReader(primary Reader, fallback Reader, required ...string) (*CompositeReader, error) {
+	t := reflect.TypeOf(ExifCompact{})
+	for _, f := range required {
+		if _, found := t.FieldByName(f); !found {
+			return nil, fmt.Errorf("unknown ExifCompact field %s", f)
+		}
+	}
+	return &CompositeReader{primary: primary, fallback: fallback, required: required}, nil
+}
+
+func (cr *CompositeReader) ExifData(path string) (*ExifData, error) {
+	data, _, err := cr.ExifDataReport(path)
+	return data, err
+}
+
+func (cr *CompositeReader) ExifCompact(path string) (*ExifCompact, error) {
+	c, _, err := cr.ExifCompactReport(path)
+	return c, err
+}
+
+// ExifDataReport is like ExifData but also reports which backend answered
+func (cr *CompositeReader) ExifDataReport(path string) (*ExifData, *ReadReport, error) {
+	report := ReadReport{Backend: backendName(cr.primary)}
+	data, err := cr.primary.ExifData(path)
+	switch {
+	case errors.Is(err, ErrFileNotFound), errors.Is(err, ErrFileUnreadable):
+		return nil, &report, err
+	case err != nil:
+		report.PrimaryErr = err
+	default:
+		report.Missing = missingFields(NewExifCompact(data), cr.required)
+		if len(report.Missing) == 0 {
+			return data, &report, nil
+		}
+	}
+
+	fallback, ferr := cr.fallback.ExifData(path)
+	if ferr != nil && data != nil {
+		report.FallbackErr = ferr
+		return data, &report, nil
+	}
+	report.Backend = backendName(cr.fallback)
+	return fallback, &report, ferr
+}
+
+func (cr *CompositeReader) ExifCompactReport(path string) (*ExifCompact, *ReadReport, error) {
+	data, report, err := cr.ExifDataReport(path)
+	if err != nil {
+		return nil, report, err
+	}
+	return NewExifCompact(data), report, nil
+}
+
+// Close closes both backends
+func (cr *CompositeReader) Close() error {
+	err1 := cr.primary.Close()
+	err2 := cr.fallback.Close()
+	if err1 != nil {
+		return err1
+	}
+	return err2
+}
+
+func missingFields(c *ExifCompact, fields []string) []string {
+	var ret []string
+	v := reflect.ValueOf(c).Elem()
+	for _, f := range fields {
+		if v.FieldByName(f).IsZero() {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+func backendName(r Reader) string {
+	switch r.(type) {
+	case *MExifTool:
+		return "exiftool"
+	case *MExifToolPool:
+		return "exiftool pool"
+	case *NativeReader:
+		return "native"
+	case *CompositeReader:
+		return "composite"
+	}
+	return fmt.Sprintf("%T", r)
+}
@@ -0,0 +1,70 @@
+package mexif
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type stubReader struct {
+	data  *ExifData
+	err   error
+	calls int
+}
+
+func (s *stubReader) ExifData(path string) (*ExifData, error) {
+	s.calls++
+	return s.data, s.err
+}
+
+func (s *stubReader) ExifCompact(path string) (*ExifCompact, error) {
+	data, err := s.ExifData(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewExifCompact(data), nil
+}
+
+func (s *stubReader) Close() error {
+	return nil
+}
+
+func TestCompositeReader(t *testing.T) {
+	titled := &ExifData{Image: map[string]interface{}{"Title": "A title"}}
+	fallback := &stubReader{data: titled}
+	cr, err := NewCompositeReader(NewNativeReader(), fallback, "CameraMake")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if c, report, err := cr.ExifCompactReport(testFiles[0]); err != nil || report.Backend != "native" || c.CameraMake == "" {
+		t.Errorf("expected native answer got %+v %v", report, err)
+	}
+	if _, _, err := cr.ExifDataReport("testdata/missing.jpg"); !errors.Is(err, ErrFileNotFound) || fallback.calls != 0 {
+		t.Errorf("expected ErrFileNotFound without fallback got %v", err)
+	}
+
+	unsupported := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(unsupported, []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if d, report, err := cr.ExifDataReport(unsupported); err != nil || d != titled ||
+		!errors.Is(report.PrimaryErr, ErrUnsupportedFormat) || report.Backend == "native" {
+		t.Errorf("expected fallback for unsupported format got %+v %v", report, err)
+	}
+
+	cr, _ = NewCompositeReader(NewNativeReader(), fallback, "CameraMake", "Title")
+	if d, report, err := cr.ExifDataReport(testFiles[0]); err != nil || d != titled ||
+		len(report.Missing) != 1 || report.Missing[0] != "Title" {
+		t.Errorf("expected fallback for missing title got %+v %v", report, err)
+	}
+	fallback.err = errors.New("fallback failed")
+	if d, report, err := cr.ExifDataReport(testFiles[0]); err != nil || d == nil || report.Backend != "native" ||
+		report.FallbackErr == nil {
+		t.Errorf("expected native data when the fallback fails got %+v %v", report, err)
+	}
+
+	if _, err := NewCompositeReader(NewNativeReader(), fallback, "NoSuchField"); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+}
@@ -0,0 +1,289 @@
+package mexif
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type ScanOptions struct {
+	//Extensions limits the scan to files with these extensions, e.g. "jpg" or ".NEF". Case insensitive
+	Extensions []string
+	//Include and Exclude are glob patterns as in filepath.Match. A pattern without a path separator
+	//is matched against the file name, otherwise against the slash separated path relative to root.
+	//Exclude patterns also apply to directories
+	Include []string
+	Exclude []string
+	//FollowSymlinks follows symlinks to files and directories, otherwise they are skipped
+	FollowSymlinks bool
+	//IncludeHidden includes files and directories whose name start with a dot
+	IncludeHidden bool
+}
+
+// ScanResult is the result for a single file. The last result sent on the channel has no
+// path and carries the Summary of the scan instead.
+type ScanResult struct {
+	Path    string
+	Data    *ExifData
+	Compact *ExifCompact
+	Err     error
+	Summary *ScanSummary
+}
+
+type ScanSummary struct {
+	Found    int
+	Read     int
+	Failed   int
+	Skipped  int
+	Errors   map[string]error
+	Duration time.Duration
+	//Err is set if the scan was abandoned before all files were read
+	Err error
+}
+
+func (tool *MExifTool) ScanDir(root string, opts ScanOptions) <-chan ScanResult {
+	return scanDir(context.Background(), tool.readArgs, 1, atomic.LoadInt64(&tool.timeout), root, opts)
+}
+
+// ScanDirContext walks root and streams the metadata of every matching file on the returned
+// channel, reading the files in batches. The channel is closed after the summary has been sent.
+func (tool *MExifTool) ScanDirContext(ctx context.Context, root string, opts ScanOptions) <-chan ScanResult {
+	return scanDir(ctx, tool.readArgs, 1, 0, root, opts)
+}
+
+func (pool *MExifToolPool) ScanDir(root string, opts ScanOptions) <-chan ScanResult {
+	return scanDir(context.Background(), pool.readArgs, cap(pool.slots), atomic.LoadInt64(&pool.timeout), root, opts)
+}
+
+func (pool *MExifToolPool) ScanDirContext(ctx context.Context, root string, opts ScanOptions) <-chan ScanResult {
+	return scanDir(ctx, pool.readArgs, cap(pool.slots), 0, root, opts)
+}
+
+type scanner struct {
+	root    string
+	opts    ScanOptions
+	exts    map[string]bool
+	visited map[string]bool
+	summary ScanSummary
+}
+
+func scanDir(ctx context.Context, read argsReader, workers int, timeout int64, root string, opts ScanOptions) <-chan ScanResult {
+	results := make(chan ScanResult, BatchSize)
+	s := scanner{
+		root:    root,
+		opts:    opts,
+		visited: map[string]bool{},
+		summary: ScanSummary{Errors: map[string]error{}},
+	}
+	for _, ext := range opts.Extensions {
+		if s.exts == nil {
+			s.exts = map[string]bool{}
+		}
+		s.exts["."+strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+	}
+
+	go func() {
+		defer close(results)
+		start := time.Now()
+		wctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		paths := make(chan string)
+		walkErrs := make(chan ScanResult)
+		go func() {
+			defer close(paths)
+			s.walk(wctx, root, paths, walkErrs)
+		}()
+
+		send := func(r ScanResult) bool {
+			if r.Err != nil {
+				s.summary.Failed++
+				s.summary.Errors[r.Path] = r.Err
+			} else {
+				s.summary.Read++
+			}
+			select {
+			case results <- r:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var pending []string
+		flush := func() bool {
+			defer func() { pending = nil }()
+			b, err := readBatch(ctx, read, workers, timeout, pending, nil)
+			if err != nil {
+				s.summary.Err = err
+			}
+			for _, p := range pending {
+				r := ScanResult{Path: p, Err: b.errs[p]}
+				if err != nil && r.Err == nil && b.entries[p] == nil {
+					r.Err = err
+				} else if e := b.entries[p]; e != nil {
+					if r.Data, r.Err = newExifData(p, e.root, e.warnings); r.Err == nil {
+						r.Compact = NewExifCompact(r.Data)
+					}
+				}
+				if !send(r) {
+					return false
+				}
+			}
+			return err == nil
+		}
+
+	loop:
+		for {
+			select {
+			case r := <-walkErrs:
+				if !send(r) {
+					break loop
+				}
+			case p, ok := <-paths:
+				if !ok {
+					if len(pending) > 0 {
+						flush()
+					}
+					break loop
+				}
+				pending = append(pending, p)
+				if len(pending) >= BatchSize*workers && !flush() {
+					break loop
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil && s.summary.Err == nil {
+			s.summary.Err = err
+		}
+		//stop the walker if the scan was abandoned
+		if s.summary.Err != nil {
+			cancel()
+		}
+		go func() {
+			for range walkErrs {
+			}
+		}()
+		for range paths {
+		}
+		close(walkErrs)
+
+		s.summary.Duration = time.Since(start)
+		select {
+		case results <- ScanResult{Summary: &s.summary}:
+		case <-ctx.Done():
+		}
+	}()
+	return results
+}
+
+// walk sends every file below dir that matches the scan options on paths. Errors are sent on
+// errs. Summary counters for found and skipped files are updated here, the rest by the reader.
+func (s *scanner) walk(ctx context.Context, dir string, paths chan<- string, errs chan<- ScanResult) {
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			select {
+			case errs <- ScanResult{Path: path, Err: err}:
+			case <-ctx.Done():
+			}
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() && s.opts.FollowSymlinks {
+			//avoid walking a directory twice or ending up in a loop
+			if real, err := filepath.EvalSymlinks(path); err == nil {
+				if s.visited[real] {
+					return filepath.SkipDir
+				}
+				s.visited[real] = true
+			}
+		}
+		if path == dir {
+			return nil
+		}
+		if !s.opts.IncludeHidden && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			s.summary.Skipped++
+			return nil
+		}
+		rel := s.relative(path)
+		if s.matches(s.opts.Exclude, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			s.summary.Skipped++
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			if !s.opts.FollowSymlinks {
+				s.summary.Skipped++
+				return nil
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				select {
+				case errs <- ScanResult{Path: path, Err: err}:
+				case <-ctx.Done():
+				}
+				return nil
+			}
+			if info.IsDir() {
+				//a trailing separator makes WalkDir resolve the link
+				s.walk(ctx, path+string(filepath.Separator), paths, errs)
+				return nil
+			}
+		} else if !d.Type().IsRegular() {
+			return nil
+		}
+
+		if s.exts != nil && !s.exts[strings.ToLower(filepath.Ext(path))] {
+			s.summary.Skipped++
+			return nil
+		}
+		if len(s.opts.Include) > 0 && !s.matches(s.opts.Include, rel) {
+			s.summary.Skipped++
+			return nil
+		}
+		s.summary.Found++
+		select {
+		case paths <- path:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+func (s *scanner) relative(path string) string {
+	if rel, err := filepath.Rel(s.root, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+func (s *scanner) matches(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		target := rel
+		if !strings.Contains(pattern, "/") {
+			target = filepath.Base(rel)
+		}
+		if ok, _ := filepath.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
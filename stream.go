@@ -0,0 +1,156 @@
+package mexif
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os/exec"
+	"sync/atomic"
+)
+
+// stdinPath tells exiftool to read the file from stdin
+const stdinPath = "-"
+
+// ReadStream is like ReadWithFlags but reads the file content from r instead of a path. The
+// stay open process only accepts paths so r is fed to a one-shot exiftool process
+func (tool *MExifTool) ReadStream(r io.Reader, flags ...string) ([]byte, error) {
+	ctx, cancel := tool.context()
+	defer cancel()
+	return tool.ReadStreamContext(ctx, r, flags...)
+}
+
+func (tool *MExifTool) ReadStreamContext(ctx context.Context, r io.Reader, flags ...string) ([]byte, error) {
+	resp, err := readStream(ctx, tool.commonFlags(), rawOutput, r, flags)
+	if err != nil {
+		return nil, err
+	}
+	return resp.stdout, nil
+}
+
+func (tool *MExifTool) ExifDataFromReader(r io.Reader) (*ExifData, error) {
+	ctx, cancel := tool.context()
+	defer cancel()
+	return tool.ExifDataFromReaderContext(ctx, r)
+}
+
+func (tool *MExifTool) ExifDataFromReaderContext(ctx context.Context, r io.Reader) (*ExifData, error) {
+	return exifDataFromReader(ctx, tool.commonFlags(), r)
+}
+
+func (tool *MExifTool) ExifDataFromBytes(b []byte) (*ExifData, error) {
+	return tool.ExifDataFromReader(bytes.NewReader(b))
+}
+
+// ExifDataFS reads the file name from fsys, e.g. an embed.FS or a zip archive
+func (tool *MExifTool) ExifDataFS(fsys fs.FS, name string) (*ExifData, error) {
+	ctx, cancel := tool.context()
+	defer cancel()
+	return tool.ExifDataFSContext(ctx, fsys, name)
+}
+
+func (tool *MExifTool) ExifDataFSContext(ctx context.Context, fsys fs.FS, name string) (*ExifData, error) {
+	return exifDataFS(ctx, tool.commonFlags(), fsys, name)
+}
+
+func (tool *MExifTool) commonFlags() []string {
+	return tool.flags[len(initArgs):]
+}
+
+func (pool *MExifToolPool) ReadStream(r io.Reader, flags ...string) ([]byte, error) {
+	ctx, cancel := timeoutContext(atomic.LoadInt64(&pool.timeout))
+	defer cancel()
+	return pool.ReadStreamContext(ctx, r, flags...)
+}
+
+func (pool *MExifToolPool) ReadStreamContext(ctx context.Context, r io.Reader, flags ...string) ([]byte, error) {
+	resp, err := readStream(ctx, pool.flags, rawOutput, r, flags)
+	if err != nil {
+		return nil, err
+	}
+	return resp.stdout, nil
+}
+
+func (pool *MExifToolPool) ExifDataFromReader(r io.Reader) (*ExifData, error) {
+	ctx, cancel := timeoutContext(atomic.LoadInt64(&pool.timeout))
+	defer cancel()
+	return pool.ExifDataFromReaderContext(ctx, r)
+}
+
+func (pool *MExifToolPool) ExifDataFromReaderContext(ctx context.Context, r io.Reader) (*ExifData, error) {
+	return exifDataFromReader(ctx, pool.flags, r)
+}
+
+func (pool *MExifToolPool) ExifDataFromBytes(b []byte) (*ExifData, error) {
+	return pool.ExifDataFromReader(bytes.NewReader(b))
+}
+
+func (pool *MExifToolPool) ExifDataFS(fsys fs.FS, name string) (*ExifData, error) {
+	ctx, cancel := timeoutContext(atomic.LoadInt64(&pool.timeout))
+	defer cancel()
+	return pool.ExifDataFSContext(ctx, fsys, name)
+}
+
+func (pool *MExifToolPool) ExifDataFSContext(ctx context.Context, fsys fs.FS, name string) (*ExifData, error) {
+	return exifDataFS(ctx, pool.flags, fsys, name)
+}
+
+func exifDataFS(ctx context.Context, common []string, fsys fs.FS, name string) (*ExifData, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return exifDataFromReader(ctx, common, f)
+}
+
+func exifDataFromReader(ctx context.Context, common []string, r io.Reader) (*ExifData, error) {
+	resp, err := readStream(ctx, common, jsonOutput, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.exifData(stdinPath)
+}
+
+func readStream(ctx context.Context, common []string, mode outputMode, r io.Reader, flags []string) (*response, error) {
+	args := append(append([]string{}, common...), jsonArgs([]string{stdinPath}, flags)...)
+	cmd := exec.CommandContext(ctx, Cmd, args...)
+	cmd.Stdin = r
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	var resp response
+	if mode == jsonOutput {
+		if err := json.NewDecoder(stdout).Decode(&resp.roots); err != nil && err != io.EOF {
+			resp.decodeErr = err
+		}
+		_, _ = io.Copy(io.Discard, stdout)
+	} else {
+		resp.stdout, _ = io.ReadAll(stdout)
+	}
+	//exiftool exits with status 1 if the file could not be read, that is reported from stderr
+	waitErr := cmd.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	resp.stderr = stderr.Bytes()
+	if err := resp.err(stdinPath); err != nil {
+		return nil, err
+	}
+	if waitErr != nil && len(resp.stdout) == 0 && len(resp.roots) == 0 {
+		return nil, &ProcessExitedError{
+			ExitCode: cmd.ProcessState.ExitCode(),
+			Stderr:   string(bytes.TrimSpace(resp.stderr)),
+			Err:      waitErr,
+		}
+	}
+	return &resp, nil
+}
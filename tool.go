@@ -1,13 +1,13 @@
 package mexif
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"os/exec"
-	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const StayOpenArg = "-stay_open"
@@ -16,75 +16,88 @@ const Cmd = "exiftool"
 
 const JsonArg = "-j"
 const GroupArg = "-g2"
+const EchoErrArg = "-echo4"
+
+const readyToken = "{ready}"
 
 var initArgs = []string{StayOpenArg, "True", "-@", "-", "-common_args"}
 
-type MExifTool struct {
-	mutex   sync.Mutex
-	stdin   io.WriteCloser
-	stdout  io.ReadCloser
-	scanout *bufio.Scanner
-	closed  bool
-}
+var ErrClosed = errors.New("MExifTool is closed")
 
-func NewMExifTool(flags ...string) (*MExifTool, error) {
-	flags = append(initArgs, flags...)
+type outputMode int
 
-	tool := MExifTool{closed: true}
+const (
+	rawOutput outputMode = iota
+	jsonOutput
+)
 
-	cmd := exec.Command(Cmd, flags...)
+type MExifTool struct {
+	lock        chan struct{}
+	flags       []string
+	timeout     int64
+	maxRestarts int
+	backoff     time.Duration
+	restarts    int
+	proc        *process
+	closed      bool
+}
 
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return nil, err
+func NewMExifTool(flags ...string) (*MExifTool, error) {
+	tool := MExifTool{
+		lock:        make(chan struct{}, 1),
+		flags:       append(initArgs, flags...),
+		maxRestarts: DefaultMaxRestarts,
+		backoff:     DefaultRestartBackoff,
 	}
-
-	stdout, err := cmd.StdoutPipe()
+	proc, err := startProcess(tool.flags)
 	if err != nil {
 		return nil, err
 	}
-	tool.stdout = stdout
-	tool.stdin = stdin
-
-	tool.scanout = bufio.NewScanner(stdout)
-	tool.scanout.Split(splitReadyToken)
-
-	if err := cmd.Start(); err != nil {
-		return nil, err
-	}
-	tool.closed = false
-
+	tool.proc = proc
 	return &tool, nil
 }
 
-func (tool *MExifTool) Close() error {
-	tool.mutex.Lock()
-	defer tool.mutex.Unlock()
+// SetTimeout sets the timeout used by the methods that do not take a context. A timeout <= 0
+// (the default) means that those methods wait for exiftool indefinitely. The timeout is per
+// file, so the batch reads such as ReadMany allow each chunk the timeout times its length.
+func (tool *MExifTool) SetTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&tool.timeout, int64(timeout))
+}
 
-	//Execute stay open false
-	fmt.Fprintln(tool.stdin, StayOpenArg)
-	fmt.Fprintln(tool.stdin, "False")
-	fmt.Fprintln(tool.stdin, ExecuteArg)
+// SetRestartPolicy controls how a crashed exiftool process is restarted. At most maxRestarts
+// consecutive restarts are made, waiting backoff before the first and doubling the wait for
+// each following one. Once exhausted the tool is closed. The default is DefaultMaxRestarts and
+// DefaultRestartBackoff.
+func (tool *MExifTool) SetRestartPolicy(maxRestarts int, backoff time.Duration) {
+	tool.lock <- struct{}{}
+	defer func() { <-tool.lock }()
+	tool.maxRestarts = maxRestarts
+	tool.backoff = backoff
+}
 
-	var errs []error
-	if err := tool.stdout.Close(); err != nil {
-		errs = append(errs, fmt.Errorf("error while closing stdout: %w", err))
-	}
+func (tool *MExifTool) Close() error {
+	tool.lock <- struct{}{}
+	defer func() { <-tool.lock }()
 
-	if err := tool.stdin.Close(); err != nil {
-		errs = append(errs, fmt.Errorf("error while closing stdin: %w", err))
+	if tool.closed {
+		return nil
 	}
 	tool.closed = true
 
-	if len(errs) > 0 {
-		return fmt.Errorf("error while closing exiftool: %w", errs)
+	if err := tool.proc.stop(); err != nil {
+		return fmt.Errorf("error while closing exiftool: %w", err)
 	}
-
 	return nil
 }
 
 func (tool *MExifTool) ExifCompact(path string) (*ExifCompact, error) {
-	if d, err := tool.ExifData(path); err == nil {
+	ctx, cancel := tool.context()
+	defer cancel()
+	return tool.ExifCompactContext(ctx, path)
+}
+
+func (tool *MExifTool) ExifCompactContext(ctx context.Context, path string) (*ExifCompact, error) {
+	if d, err := tool.ExifDataContext(ctx, path); err == nil {
 		return NewExifCompact(d), nil
 	} else {
 		return nil, err
@@ -92,27 +105,31 @@ func (tool *MExifTool) ExifCompact(path string) (*ExifCompact, error) {
 }
 
 func (tool *MExifTool) ExifData(path string) (*ExifData, error) {
-	root, err := tool.Unmarshal(path)
+	ctx, cancel := tool.context()
+	defer cancel()
+	return tool.ExifDataContext(ctx, path)
+}
+
+func (tool *MExifTool) ExifDataContext(ctx context.Context, path string) (*ExifData, error) {
+	resp, err := tool.readResponse(ctx, jsonOutput, path)
 	if err != nil {
 		return nil, err
 	}
-	return NewExifData(root), nil
+	return resp.exifData(path)
 }
 
 func (tool *MExifTool) Unmarshal(path string) (map[string]interface{}, error) {
-	bytes, err := tool.Read(path)
-	if err != nil {
-		return nil, err
-	}
-	var f []interface{}
-	err = json.Unmarshal(bytes, &f)
+	ctx, cancel := tool.context()
+	defer cancel()
+	return tool.UnmarshalContext(ctx, path)
+}
+
+func (tool *MExifTool) UnmarshalContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	resp, err := tool.readResponse(ctx, jsonOutput, path)
 	if err != nil {
 		return nil, err
 	}
-	if len(f) < 1 {
-		return nil, fmt.Errorf("no data")
-	}
-	return f[0].(map[string]interface{}), nil
+	return resp.first()
 }
 
 func (tool *MExifTool) Read(path string) ([]byte, error) {
@@ -120,52 +137,166 @@ func (tool *MExifTool) Read(path string) ([]byte, error) {
 }
 
 func (tool *MExifTool) ReadWithFlags(path string, flags ...string) ([]byte, error) {
-	tool.mutex.Lock()
-	defer tool.mutex.Unlock()
+	ctx, cancel := tool.context()
+	defer cancel()
+	return tool.ReadContext(ctx, path, flags...)
+}
 
-	if tool.closed {
-		return nil, fmt.Errorf("MExifTool is closed")
-	}
-	for _, f := range flags {
-		fmt.Fprintln(tool.stdin, f)
+// ReadContext is like ReadWithFlags but gives up when ctx is cancelled or its deadline expires.
+// An abandoned request kills the exiftool process and starts a new one so that the output of
+// subsequent requests stays in sync. If exiftool dies while processing the request it is
+// restarted according to the restart policy and the request is retried once.
+func (tool *MExifTool) ReadContext(ctx context.Context, path string, flags ...string) ([]byte, error) {
+	resp, err := tool.readResponse(ctx, rawOutput, path, flags...)
+	if err != nil {
+		return nil, err
 	}
-	fmt.Fprintln(tool.stdin, JsonArg)
-	fmt.Fprintln(tool.stdin, GroupArg)
-	fmt.Fprintln(tool.stdin, path)
-	fmt.Fprintln(tool.stdin, ExecuteArg)
+	return resp.stdout, nil
+}
 
-	//read output
-	if !tool.scanout.Scan() {
-		return nil, fmt.Errorf("Failed to read output")
-	} else {
-		results := tool.scanout.Bytes()
-		sendResults := make([]byte, len(results), len(results))
-		copy(sendResults, results)
-		return sendResults, nil
+func (tool *MExifTool) readResponse(ctx context.Context, mode outputMode, path string, flags ...string) (*response, error) {
+	resp, err := tool.readArgs(ctx, mode, jsonArgs([]string{path}, flags))
+	if err != nil {
+		return nil, err
 	}
+	return resp, resp.err(path)
 }
 
-func splitReadyToken(data []byte, atEOF bool) (int, []byte, error) {
-	delimPos := bytes.Index(data, []byte("{ready}\n"))
-	delimSize := 8
+func (tool *MExifTool) readArgs(ctx context.Context, mode outputMode, args []string) (*response, error) {
+	select {
+	case tool.lock <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-tool.lock }()
 
-	//windows
-	if delimPos == -1 {
-		delimPos = bytes.Index(data, []byte("{ready}\r\n"))
-		delimSize = 9
+	retried := false
+	for {
+		if err := tool.ensureRunning(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := tool.execute(ctx, mode, args)
+		if err == nil {
+			tool.restarts = 0
+			return resp, nil
+		}
+		if retried || !errors.Is(err, ErrProcessExited) {
+			return nil, err
+		}
+		retried = true
 	}
+}
 
-	if delimPos == -1 { // still no token found
-		if atEOF {
-			return 0, data, io.EOF
+// ensureRunning restarts the exiftool process if it has exited. Must be called while holding
+// the lock
+func (tool *MExifTool) ensureRunning(ctx context.Context) error {
+	if tool.closed {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !tool.proc.hasExited() {
+		return nil
+	}
+	exitErr := tool.proc.exitError()
+	if tool.restarts >= tool.maxRestarts {
+		tool.closed = true
+		return fmt.Errorf("%w: giving up after %d restarts: %w", ErrClosed, tool.restarts, exitErr)
+	}
+	tool.restarts++
+	select {
+	case <-time.After(restartBackoff(tool.backoff, tool.restarts)):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	proc, err := startProcess(tool.flags)
+	if err != nil {
+		tool.closed = true
+		return fmt.Errorf("could not restart exiftool: %w", err)
+	}
+	tool.proc = proc
+	return nil
+}
+
+// execute sends args to exiftool and reads the response. The output is either kept as is or
+// decoded as JSON while it is read depending on mode. Must be called while holding the lock
+func (tool *MExifTool) execute(ctx context.Context, mode outputMode, args []string) (*response, error) {
+	proc := tool.proc
+	var resp response
+	done := make(chan error, 1)
+	go func() {
+		for _, a := range args {
+			fmt.Fprintln(proc.stdin, a)
+		}
+		fmt.Fprintln(proc.stdin, EchoErrArg)
+		fmt.Fprintln(proc.stdin, readyToken)
+		fmt.Fprintln(proc.stdin, ExecuteArg)
+
+		//read output
+		rr := newReadyReader(proc.stdout)
+		if mode == jsonOutput {
+			if err := json.NewDecoder(rr).Decode(&resp.roots); err != nil && err != io.EOF {
+				resp.decodeErr = err
+			}
 		} else {
-			return 0, nil, nil
+			resp.stdout, _ = io.ReadAll(rr)
 		}
-	} else {
-		if atEOF && len(data) == (delimPos+delimSize) { // nothing left to scan
-			return delimPos + delimSize, data[:delimPos], bufio.ErrFinalToken
+		//make sure everything up to the ready token is consumed even if decoding failed
+		if _, err := io.Copy(io.Discard, rr); err != nil {
+			done <- fmt.Errorf("Failed to read output: %w", err)
+			return
+		}
+
+		select {
+		case resp.stderr = <-proc.errout:
+			done <- nil
+		case <-proc.exited:
+			done <- fmt.Errorf("Failed to read error output")
+		}
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			return &resp, nil
+		}
+		//give the supervisor a chance to reap a crashed process
+		select {
+		case <-proc.exited:
+			return nil, proc.exitError()
+		case <-time.After(time.Second):
+			//the output stream is out of sync, start over with a fresh process
+			proc.kill()
+			return nil, err
+		}
+	case <-ctx.Done():
+		proc.kill()
+		<-done
+		if proc, err := startProcess(tool.flags); err != nil {
+			tool.closed = true
+			return nil, fmt.Errorf("%w (restart of exiftool failed: %v)", ctx.Err(), err)
 		} else {
-			return delimPos + delimSize, data[:delimPos], nil
+			tool.proc = proc
 		}
+		return nil, ctx.Err()
 	}
 }
+
+func (tool *MExifTool) context() (context.Context, context.CancelFunc) {
+	return timeoutContext(atomic.LoadInt64(&tool.timeout))
+}
+
+func timeoutContext(timeout int64) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), time.Duration(timeout))
+	}
+	return context.WithCancel(context.Background())
+}
+
+func jsonArgs(paths []string, flags []string) []string {
+	args := make([]string, 0, len(flags)+len(paths)+2)
+	args = append(args, flags...)
+	args = append(args, JsonArg, GroupArg)
+	return append(args, paths...)
+}
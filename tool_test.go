@@ -0,0 +1,293 @@
+package mexif
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testFiles = []string{
+	"testdata/DSCF1323.jpg",
+	"testdata/DSC_0685.jpg",
+	"testdata/L1000114.jpg",
+}
+
+func newTestTool(t *testing.T) *MExifTool {
+	t.Helper()
+	if _, err := exec.LookPath(Cmd); err != nil {
+		t.Skip("exiftool not installed")
+	}
+	tool, err := NewMExifTool()
+	if err != nil {
+		t.Fatalf("could not start exiftool: %v", err)
+	}
+	t.Cleanup(func() { _ = tool.Close() })
+	return tool
+}
+
+func TestExifData(t *testing.T) {
+	tool := newTestTool(t)
+	for _, f := range testFiles {
+		if d, err := tool.ExifData(f); err != nil {
+			t.Errorf("unexpected error for %s: %v", f, err)
+		} else if _, found := d.Camera["Make"]; !found {
+			t.Errorf("expected camera make for %s", f)
+		}
+	}
+}
+
+func TestPool(t *testing.T) {
+	if _, err := exec.LookPath(Cmd); err != nil {
+		t.Skip("exiftool not installed")
+	}
+	pool, err := NewMExifToolPool(1, 3)
+	if err != nil {
+		t.Fatalf("could not start pool: %v", err)
+	}
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func(f string) {
+			defer wg.Done()
+			if _, err := pool.ExifCompact(f); err != nil {
+				t.Errorf("unexpected error for %s: %v", f, err)
+			}
+		}(testFiles[i%len(testFiles)])
+	}
+	wg.Wait()
+	if s := pool.Size(); s < 1 || s > 3 {
+		t.Errorf("expected pool size between 1 and 3 got %v", s)
+	}
+	if err := pool.Close(); err != nil {
+		t.Errorf("unexpected error closing pool: %v", err)
+	}
+	if _, err := pool.ExifData(testFiles[0]); err == nil {
+		t.Errorf("expected error reading from closed pool")
+	}
+}
+
+func TestReadContext(t *testing.T) {
+	tool := newTestTool(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tool.ExifDataContext(ctx, testFiles[0]); err != context.Canceled {
+		t.Errorf("expected context.Canceled got %v", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := tool.ExifDataContext(ctx, testFiles[0]); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	tool := newTestTool(t)
+	tool.SetRestartPolicy(2, time.Millisecond)
+	if _, err := tool.ExifData(testFiles[0]); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	//the first kill is not waited for so that the request fails and is retried, the second is
+	//reaped before the next request so that the process is restarted before it is used
+	for i, wait := range []bool{false, true} {
+		proc := tool.proc
+		_ = proc.cmd.Process.Kill()
+		if wait {
+			<-proc.exited
+		}
+		if d, err := tool.ExifData(testFiles[i]); err != nil {
+			t.Fatalf("expected the request to be retried got %v", err)
+		} else if len(d.Camera) == 0 {
+			t.Errorf("expected camera data")
+		}
+		if tool.proc == proc {
+			t.Errorf("expected a new process")
+		}
+		if tool.restarts != 0 {
+			t.Errorf("expected restarts to be reset got %d", tool.restarts)
+		}
+	}
+}
+
+func TestRestartsExhausted(t *testing.T) {
+	tool := newTestTool(t)
+	tool.SetRestartPolicy(0, time.Millisecond)
+	tool.proc.kill()
+	_, err := tool.ExifData(testFiles[0])
+	var exitErr *ProcessExitedError
+	if !errors.Is(err, ErrProcessExited) || !errors.Is(err, ErrClosed) || !errors.As(err, &exitErr) {
+		t.Errorf("expected ErrProcessExited and ErrClosed got %v", err)
+	}
+	if _, err := tool.ExifData(testFiles[0]); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed got %v", err)
+	}
+}
+
+func TestClosed(t *testing.T) {
+	tool := newTestTool(t)
+	if err := tool.Close(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := tool.ExifData(testFiles[0]); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed got %v", err)
+	}
+	if _, err := tool.ExifDataContext(context.Background(), testFiles[0]); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed got %v", err)
+	}
+	if err := tool.Close(); err != nil {
+		t.Errorf("expected closing twice to succeed got %v", err)
+	}
+}
+
+func TestFileNotFound(t *testing.T) {
+	tool := newTestTool(t)
+	if _, err := tool.ExifData("testdata/missing.jpg"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("expected ErrFileNotFound got %v", err)
+	}
+	if _, err := tool.ExifData(testFiles[0]); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestExifDataMany(t *testing.T) {
+	tool := newTestTool(t)
+	paths := append([]string{"testdata/missing.jpg"}, testFiles...)
+	data, err := tool.ExifDataMany(paths)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected BatchError got %v", err)
+	}
+	if len(batchErr.Errors) != 1 || !errors.Is(batchErr.Errors["testdata/missing.jpg"], ErrFileNotFound) {
+		t.Errorf("expected ErrFileNotFound for missing file got %v", batchErr.Errors)
+	}
+	for _, f := range testFiles {
+		if _, found := data[f]; !found {
+			t.Errorf("expected data for %s", f)
+		}
+	}
+}
+
+func TestScanDir(t *testing.T) {
+	tool := newTestTool(t)
+	var files []string
+	var summary *ScanSummary
+	for r := range tool.ScanDir("testdata", ScanOptions{Extensions: []string{"JPG"}}) {
+		if r.Summary != nil {
+			summary = r.Summary
+		} else if r.Err != nil {
+			t.Errorf("unexpected error for %s: %v", r.Path, r.Err)
+		} else if r.Compact == nil {
+			t.Errorf("expected compact data for %s", r.Path)
+		} else {
+			files = append(files, r.Path)
+		}
+	}
+	if len(files) != len(testFiles) {
+		t.Errorf("expected %d files got %v", len(testFiles), files)
+	}
+	if summary == nil || summary.Found != len(testFiles) || summary.Read != len(testFiles) {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+}
+
+func TestExifDataFromBytes(t *testing.T) {
+	tool := newTestTool(t)
+	b, err := os.ReadFile(testFiles[0])
+	if err != nil {
+		t.Fatalf("could not read test file: %v", err)
+	}
+	if d, err := tool.ExifDataFromBytes(b); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if len(d.Camera) == 0 {
+		t.Errorf("expected camera data")
+	}
+	if _, err := tool.ExifDataFS(os.DirFS("testdata"), "DSC_0685.jpg"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := tool.ExifDataFS(os.DirFS("testdata"), "missing.jpg"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tool.ExifDataFSContext(ctx, os.DirFS("testdata"), "DSC_0685.jpg"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled got %v", err)
+	}
+	pool, err := NewMExifToolPool(1, 1)
+	if err != nil {
+		t.Fatalf("could not start pool: %v", err)
+	}
+	defer pool.Close()
+	if _, err := pool.ExifDataFSContext(context.Background(), os.DirFS("testdata"), "DSC_0685.jpg"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := pool.ExifDataFSContext(ctx, os.DirFS("testdata"), "DSC_0685.jpg"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled got %v", err)
+	}
+}
+
+func TestNativeReader(t *testing.T) {
+	nr := NewNativeReader()
+	defer nr.Close()
+	c, err := nr.ExifCompact("testdata/L1000114.jpg")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if c.CameraModel != "LEICA Q2" || c.ExposureTime != "1/250" || c.FNumber != 2.5 || c.ISO != 100 ||
+		c.ImageWidth != 1080 || c.OriginalDate.Format(time.RFC3339) != "2020-01-12T11:31:49+02:00" ||
+		c.ExposureSeconds != 0.004 || c.FocalLengthMM != 28 || c.FocalLength35mm != 28 {
+		t.Errorf("unexpected compact data %+v", c)
+	}
+	if _, err := nr.ExifData("testdata/missing.jpg"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("expected ErrFileNotFound got %v", err)
+	}
+
+	//the native data should match exiftool for the fields of ExifCompact
+	if _, err := exec.LookPath(Cmd); err != nil {
+		return
+	}
+	tool := newTestTool(t)
+	for _, f := range testFiles {
+		expected, err := tool.ExifCompact(f)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", f, err)
+		}
+		actual, err := nr.ExifCompact(f)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", f, err)
+		}
+		for name := range compactTags {
+			if name != "Title" && name != "Keywords" && name != "City" && name != "State" && name != "Country" &&
+				!compactFieldEqual(name, expected, actual) {
+				t.Errorf("%s: %s differs from exiftool", f, name)
+			}
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	tool := newTestTool(t)
+	b, err := tool.Preview(testFiles[0], ThumbnailImage)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !bytes.HasPrefix(b, []byte{0xff, 0xd8}) || !bytes.HasSuffix(b, []byte{0xff, 0xd9}) {
+		t.Errorf("expected a JPEG thumbnail got %d bytes", len(b))
+	}
+	if _, err := tool.Preview(testFiles[0], JpgFromRaw); !errors.Is(err, ErrNoPreview) {
+		t.Errorf("expected ErrNoPreview got %v", err)
+	}
+	img, err := tool.DecodePreview(testFiles[0])
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if img.Bounds().Empty() {
+		t.Errorf("expected a non empty preview")
+	}
+}
@@ -0,0 +1,47 @@
+//go:build unix
+
+package mexif
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestReadDeadline lets the deadline expire while exiftool is blocked opening a fifo that is
+// never written to, so the process has to be killed and restarted
+func TestReadDeadline(t *testing.T) {
+	tool := newTestTool(t)
+	path := filepath.Join(t.TempDir(), "hang.jpg")
+	if err := syscall.Mkfifo(path, 0644); err != nil {
+		t.Skipf("could not create fifo: %v", err)
+	}
+	proc := tool.proc
+	timeout := 300 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	if _, err := tool.ExifDataContext(ctx, path); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded got %v", err)
+	}
+	if time.Since(start) < timeout {
+		t.Errorf("expected the request to be cancelled while exiftool was working")
+	}
+	if tool.proc == proc {
+		t.Errorf("expected exiftool to be restarted")
+	}
+
+	//the following requests must only see their own output
+	for _, f := range testFiles {
+		resp, err := tool.readResponse(context.Background(), jsonOutput, f)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", f, err)
+		}
+		if len(resp.roots) != 1 || resp.roots[0]["SourceFile"] != f {
+			t.Errorf("expected output for %s got %v", f, resp.roots)
+		}
+	}
+}
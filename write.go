@@ -0,0 +1,291 @@
+package mexif
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const OverwriteOriginalArg = "-overwrite_original"
+const PreserveTimeArg = "-P"
+const TagsFromFileArg = "-tagsFromFile"
+const EscapeHTMLArg = "-E"
+
+type WriteOptions struct {
+	//OverwriteOriginal writes the file in place. By default exiftool keeps the original as <file>_original
+	OverwriteOriginal bool
+	//PreserveTime keeps the file modification date
+	PreserveTime bool
+	//Append adds the values of list tags such as Keywords to the existing ones instead of replacing them
+	Append bool
+}
+
+// WriteResult holds the file counts exiftool reports after writing
+type WriteResult struct {
+	Updated   int
+	Unchanged int
+	Created   int
+	Failed    int
+	Warnings  []string
+}
+
+var writeSummary = regexp.MustCompile(`^\s*(\d+) (?:image )?files? (updated|unchanged|created|weren't updated due to errors)`)
+
+// WriteTags sets the tags of path. A tag name may be prefixed with a group, e.g. "XMP:Title",
+// and end with + or - to add or remove list values as in exiftool. A nil value deletes the tag,
+// slices write one value per element and time.Time values are written with their offset.
+func (tool *MExifTool) WriteTags(path string, tags map[string]interface{}, opts WriteOptions) (*WriteResult, error) {
+	ctx, cancel := tool.context()
+	defer cancel()
+	return tool.WriteTagsContext(ctx, path, tags, opts)
+}
+
+func (tool *MExifTool) WriteTagsContext(ctx context.Context, path string, tags map[string]interface{}, opts WriteOptions) (*WriteResult, error) {
+	return writeTags(ctx, tool.readArgs, path, tags, opts)
+}
+
+// DeleteTags deletes the given tags from path. Use "all" to delete all writable tags
+func (tool *MExifTool) DeleteTags(path string, tags []string, opts WriteOptions) (*WriteResult, error) {
+	ctx, cancel := tool.context()
+	defer cancel()
+	return tool.DeleteTagsContext(ctx, path, tags, opts)
+}
+
+func (tool *MExifTool) DeleteTagsContext(ctx context.Context, path string, tags []string, opts WriteOptions) (*WriteResult, error) {
+	return deleteTags(ctx, tool.readArgs, path, tags, opts)
+}
+
+// CopyTags copies the tags of the given groups, e.g. "EXIF" or "XMP", from src to dst. Without
+// groups all writable tags are copied.
+func (tool *MExifTool) CopyTags(src, dst string, groups ...string) (*WriteResult, error) {
+	ctx, cancel := tool.context()
+	defer cancel()
+	return tool.CopyTagsContext(ctx, src, dst, WriteOptions{}, groups...)
+}
+
+func (tool *MExifTool) CopyTagsContext(ctx context.Context, src, dst string, opts WriteOptions, groups ...string) (*WriteResult, error) {
+	return copyTags(ctx, tool.readArgs, src, dst, opts, groups)
+}
+
+func (pool *MExifToolPool) WriteTags(path string, tags map[string]interface{}, opts WriteOptions) (*WriteResult, error) {
+	ctx, cancel := timeoutContext(atomic.LoadInt64(&pool.timeout))
+	defer cancel()
+	return pool.WriteTagsContext(ctx, path, tags, opts)
+}
+
+func (pool *MExifToolPool) WriteTagsContext(ctx context.Context, path string, tags map[string]interface{}, opts WriteOptions) (*WriteResult, error) {
+	return writeTags(ctx, pool.readArgs, path, tags, opts)
+}
+
+func (pool *MExifToolPool) DeleteTags(path string, tags []string, opts WriteOptions) (*WriteResult, error) {
+	ctx, cancel := timeoutContext(atomic.LoadInt64(&pool.timeout))
+	defer cancel()
+	return pool.DeleteTagsContext(ctx, path, tags, opts)
+}
+
+func (pool *MExifToolPool) DeleteTagsContext(ctx context.Context, path string, tags []string, opts WriteOptions) (*WriteResult, error) {
+	return deleteTags(ctx, pool.readArgs, path, tags, opts)
+}
+
+func (pool *MExifToolPool) CopyTags(src, dst string, groups ...string) (*WriteResult, error) {
+	ctx, cancel := timeoutContext(atomic.LoadInt64(&pool.timeout))
+	defer cancel()
+	return pool.CopyTagsContext(ctx, src, dst, WriteOptions{}, groups...)
+}
+
+func (pool *MExifToolPool) CopyTagsContext(ctx context.Context, src, dst string, opts WriteOptions, groups ...string) (*WriteResult, error) {
+	return copyTags(ctx, pool.readArgs, src, dst, opts, groups)
+}
+
+func writeTags(ctx context.Context, read argsReader, path string, tags map[string]interface{}, opts WriteOptions) (*WriteResult, error) {
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("no tags to write")
+	}
+	//sort the tags to get a stable order of the arguments
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var assignments []string
+	for _, name := range names {
+		a, err := tagAssignments(name, tags[name], opts.Append)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a...)
+	}
+
+	//arguments are sent one per line so values with line breaks have to be escaped
+	escape := false
+	for _, a := range assignments {
+		if strings.ContainsAny(a, "\r\n") {
+			escape = true
+		}
+	}
+	args := writeArgs(opts)
+	if escape {
+		args = append(args, EscapeHTMLArg)
+		for i, a := range assignments {
+			a = html.EscapeString(a)
+			a = strings.ReplaceAll(a, "\r", "&#xd;")
+			assignments[i] = strings.ReplaceAll(a, "\n", "&#xa;")
+		}
+	}
+	args = append(args, assignments...)
+	return write(ctx, read, path, append(args, path))
+}
+
+func deleteTags(ctx context.Context, read argsReader, path string, tags []string, opts WriteOptions) (*WriteResult, error) {
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("no tags to delete")
+	}
+	args := writeArgs(opts)
+	for _, tag := range tags {
+		args = append(args, "-"+tag+"=")
+	}
+	return write(ctx, read, path, append(args, path))
+}
+
+func copyTags(ctx context.Context, read argsReader, src, dst string, opts WriteOptions, groups []string) (*WriteResult, error) {
+	args := append(writeArgs(opts), TagsFromFileArg, src)
+	for _, g := range groups {
+		args = append(args, "-"+g+":all")
+	}
+	return write(ctx, read, dst, append(args, dst))
+}
+
+func writeArgs(opts WriteOptions) []string {
+	var args []string
+	if opts.OverwriteOriginal {
+		args = append(args, OverwriteOriginalArg)
+	}
+	if opts.PreserveTime {
+		args = append(args, PreserveTimeArg)
+	}
+	return args
+}
+
+// tagAssignments converts a tag and its value to exiftool arguments
+func tagAssignments(name string, value interface{}, appendList bool) ([]string, error) {
+	op := "="
+	if strings.HasSuffix(name, "+") || strings.HasSuffix(name, "-") {
+		op = name[len(name)-1:] + "="
+		name = name[:len(name)-1]
+	}
+	if name == "" || strings.ContainsAny(name, "=\r\n") {
+		return nil, fmt.Errorf("invalid tag name %q", name)
+	}
+
+	var values []string
+	list := false
+	switch v := value.(type) {
+	case nil:
+		if op != "=" {
+			return nil, fmt.Errorf("tag %s: nil value can only be used to delete a tag", name)
+		}
+		return []string{"-" + name + "="}, nil
+	case []string:
+		values, list = v, true
+	case []interface{}:
+		list = true
+		for _, e := range v {
+			s, err := tagValue(e)
+			if err != nil {
+				return nil, fmt.Errorf("tag %s: %w", name, err)
+			}
+			values = append(values, s)
+		}
+	default:
+		s, err := tagValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("tag %s: %w", name, err)
+		}
+		values = []string{s}
+	}
+	//+= on a scalar tag would increment numbers and shift dates instead of setting them
+	if op == "=" && appendList && list && len(values) > 0 {
+		op = "+="
+	}
+
+	ret := make([]string, 0, len(values))
+	for _, v := range values {
+		ret = append(ret, "-"+name+op+v)
+	}
+	return ret, nil
+}
+
+func tagValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return v.Format("2006:01:02 15:04:05-07:00"), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+func write(ctx context.Context, read argsReader, path string, args []string) (*WriteResult, error) {
+	resp, err := read(ctx, rawOutput, args)
+	if err != nil {
+		return nil, err
+	}
+	result := parseWriteResult(resp.stdout)
+	result.Warnings = resp.messages(warningPrefix)
+	if result.Failed > 0 || result.Updated+result.Unchanged+result.Created == 0 {
+		if errs := resp.messages(errorPrefix); len(errs) > 0 {
+			return result, newExifToolError(path, errs[0])
+		}
+		return result, newExifToolError(path, "file was not updated")
+	}
+	return result, nil
+}
+
+func parseWriteResult(stdout []byte) *WriteResult {
+	result := WriteResult{}
+	scanner := bufio.NewScanner(bytes.NewReader(stdout))
+	for scanner.Scan() {
+		m := writeSummary.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "updated":
+			result.Updated += n
+		case "unchanged":
+			result.Unchanged += n
+		case "created":
+			result.Created += n
+		default:
+			result.Failed += n
+		}
+	}
+	return &result
+}
@@ -0,0 +1,169 @@
+package mexif
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTagAssignments(t *testing.T) {
+	loc := time.FixedZone("", 3600)
+	tests := []struct {
+		name     string
+		value    interface{}
+		append   bool
+		expected []string
+	}{
+		{"Title", "title", false, []string{"-Title=title"}},
+		{"Rating", 5, false, []string{"-Rating=5"}},
+		{"Title", nil, false, []string{"-Title="}},
+		{"Keywords", []string{"a", "b"}, false, []string{"-Keywords=a", "-Keywords=b"}},
+		{"Keywords", []string{"a", "b"}, true, []string{"-Keywords+=a", "-Keywords+=b"}},
+		{"Keywords", []interface{}{"a"}, true, []string{"-Keywords+=a"}},
+		{"Rating", 3, true, []string{"-Rating=3"}},
+		{"Title", "title", true, []string{"-Title=title"}},
+		{"XMP:Subject-", []interface{}{"a"}, false, []string{"-XMP:Subject-=a"}},
+		{"DateTimeOriginal", time.Date(2020, 1, 1, 15, 1, 1, 0, loc), false, []string{"-DateTimeOriginal=2020:01:01 15:01:01+01:00"}},
+	}
+	for _, test := range tests {
+		if a, err := tagAssignments(test.name, test.value, test.append); err != nil {
+			t.Errorf("unexpected error %v", err)
+		} else if !reflect.DeepEqual(a, test.expected) {
+			t.Errorf("expected %v got %v", test.expected, a)
+		}
+	}
+	if _, err := tagAssignments("Title", struct{}{}, false); err == nil {
+		t.Errorf("expected error for unsupported type")
+	}
+}
+
+func TestWriteTagsAppend(t *testing.T) {
+	var args []string
+	read := func(ctx context.Context, mode outputMode, a []string) (*response, error) {
+		args = a
+		return &response{stdout: []byte("    1 image files updated\n")}, nil
+	}
+	tags := map[string]interface{}{
+		"Rating":           3,
+		"DateTimeOriginal": "2020:01:01 15:01:01",
+		"Keywords":         []string{"a", "b"},
+	}
+	if _, err := writeTags(context.Background(), read, "a.jpg", tags, WriteOptions{Append: true}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []string{"-DateTimeOriginal=2020:01:01 15:01:01", "-Keywords+=a", "-Keywords+=b", "-Rating=3", "a.jpg"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %v got %v", expected, args)
+	}
+}
+
+func TestParseWriteResult(t *testing.T) {
+	r := parseWriteResult([]byte("    1 image files updated\n    2 image files unchanged\n    1 files weren't updated due to errors\n"))
+	if r.Updated != 1 || r.Unchanged != 2 || r.Failed != 1 {
+		t.Errorf("unexpected result %+v", r)
+	}
+}
+
+func TestWriteTags(t *testing.T) {
+	tool := newTestTool(t)
+	b, err := os.ReadFile(testFiles[1])
+	if err != nil {
+		t.Fatalf("could not read test file: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "test.jpg")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("could not write test file: %v", err)
+	}
+	tags := map[string]interface{}{"Title": "mexif", "Keywords": []string{"a", "b"}}
+	if r, err := tool.WriteTags(path, tags, WriteOptions{OverwriteOriginal: true}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if r.Updated != 1 {
+		t.Errorf("expected 1 updated file got %+v", r)
+	}
+	if _, err := tool.WriteTags(filepath.Join(t.TempDir(), "missing.jpg"), tags, WriteOptions{}); err == nil {
+		t.Errorf("expected error writing missing file")
+	}
+}
+
+func TestWriteCompact(t *testing.T) {
+	tool := newTestTool(t)
+	b, err := os.ReadFile(testFiles[1])
+	if err != nil {
+		t.Fatalf("could not read test file: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "test.jpg")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("could not write test file: %v", err)
+	}
+	c, err := tool.ExifCompact(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	c.Title = "mexif"
+	c.Keywords = []string{"a", "b"}
+	opts := WriteOptions{OverwriteOriginal: true}
+	if _, err := tool.WriteCompactContext(context.Background(), path, c, opts, "Title", "Keywords"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if r, err := tool.WriteCompactContext(context.Background(), path, c, opts); err != nil {
+		t.Errorf("unexpected error %v", err)
+	} else if r.Unchanged != 1 {
+		t.Errorf("expected no changes got %+v", r)
+	}
+	if _, err := tool.WriteCompact(path, c, "ImageWidth"); err == nil {
+		t.Errorf("expected error for field that can not be written")
+	}
+}
+
+func TestCompactFieldEqual(t *testing.T) {
+	a := ExifCompact{FNumber: 5.6, OriginalDate: time.Date(2020, 1, 1, 15, 0, 0, 0, time.UTC)}
+	b := ExifCompact{FNumber: 5.6, OriginalDate: a.OriginalDate.In(time.FixedZone("", 3600)), Keywords: []string{}}
+	for f := range compactTags {
+		if !compactFieldEqual(f, &a, &b) {
+			t.Errorf("expected %s to be equal", f)
+		}
+	}
+	b.Title = "title"
+	if compactFieldEqual("Title", &a, &b) {
+		t.Errorf("expected Title to differ")
+	}
+	//0 deg 7' 40.00" W
+	a.GPSLongitude, b.GPSLongitude = -0.1277764, -(7.0/60 + 40.0/3600)
+	if !compactFieldEqual("GPSLongitude", &a, &b) {
+		t.Errorf("expected GPSLongitude near 0 to be equal")
+	}
+	b.GPSLongitude = -0.1278
+	if compactFieldEqual("GPSLongitude", &a, &b) {
+		t.Errorf("expected GPSLongitude to differ")
+	}
+}
+
+func TestWriteCompactNumbers(t *testing.T) {
+	tool := newTestTool(t)
+	b, err := os.ReadFile(testFiles[1])
+	if err != nil {
+		t.Fatalf("could not read test file: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "test.jpg")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("could not write test file: %v", err)
+	}
+	c := ExifCompact{ExposureCompensation: -1.0 / 3, GPSLatitude: 51.5073219, GPSLongitude: -0.1277764}
+	opts := WriteOptions{OverwriteOriginal: true}
+	fields := []string{"ExposureCompensation", "GPSLatitude", "GPSLongitude"}
+	if _, err := tool.WriteCompactContext(context.Background(), path, &c, opts, fields...); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	written, err := tool.ExifCompact(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if written.ExposureCompensation != c.ExposureCompensation || math.Abs(written.GPSLongitude-c.GPSLongitude) > gpsTolerance {
+		t.Errorf("expected %v %v got %v %v", c.ExposureCompensation, c.GPSLongitude, written.ExposureCompensation,
+			written.GPSLongitude)
+	}
+}
@@ -0,0 +1,264 @@
+package mexif
+
+import (
+	"context"
+	"fmt"
+	"github.com/msvens/mexif/json"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// VerifyError is returned by WriteCompact when the fields read back after writing differ from
+// the ones that were written
+type VerifyError struct {
+	Path   string
+	Fields []string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("fields not written as expected to %s: %s", e.Path, strings.Join(e.Fields, ", "))
+}
+
+// compactTags maps the writable ExifCompact fields to the tags they are written to. A nil
+// value deletes the tag
+var compactTags = map[string]func(c *ExifCompact) map[string]interface{}{
+	"Title": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"XMP-dc:Title": optString(c.Title)}
+	},
+	"Keywords": func(c *ExifCompact) map[string]interface{} {
+		var kw interface{}
+		if len(c.Keywords) > 0 {
+			kw = c.Keywords
+		}
+		return map[string]interface{}{"IPTC:Keywords": kw, "XMP-dc:Subject": kw}
+	},
+	"Software": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:Software": optString(c.Software)}
+	},
+	"Rating": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"XMP-xmp:Rating": optNumber(c.Rating), "EXIF:Rating": optNumber(c.Rating)}
+	},
+	"CameraMake": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:Make": optString(c.CameraMake)}
+	},
+	"CameraModel": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:Model": optString(c.CameraModel)}
+	},
+	"LensInfo": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:LensInfo": optString(c.LensInfo)}
+	},
+	"LensModel": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:LensModel": optString(c.LensModel)}
+	},
+	"LensMake": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:LensMake": optString(c.LensMake)}
+	},
+	"FocalLength": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:FocalLength": optString(c.FocalLength)}
+	},
+	"FocalLengthIn35mmFormat": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:FocalLengthIn35mmFormat": optString(c.FocalLengthIn35mmFormat)}
+	},
+	"MaxApertureValue": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:MaxApertureValue": optNumber(c.MaxApertureValue)}
+	},
+	"Flash": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:Flash": optString(c.Flash)}
+	},
+	"ExposureTime": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:ExposureTime": optString(c.ExposureTime)}
+	},
+	"ExposureCompensation": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:ExposureCompensation": optNumber(c.ExposureCompensation)}
+	},
+	"ExposureProgram": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:ExposureProgram": optString(c.ExposureProgram)}
+	},
+	"FNumber": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:FNumber": optNumber(c.FNumber)}
+	},
+	"ISO": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:ISO": optNumber(c.ISO)}
+	},
+	"ColorSpace": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:ColorSpace": optString(c.ColorSpace)}
+	},
+	"XResolution": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:XResolution": optNumber(c.XResolution)}
+	},
+	"YResolution": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"EXIF:YResolution": optNumber(c.YResolution)}
+	},
+	"OriginalDate": func(c *ExifCompact) map[string]interface{} {
+		return dateTags(c.OriginalDate, "EXIF:DateTimeOriginal", "EXIF:SubSecTimeOriginal", "EXIF:OffsetTimeOriginal")
+	},
+	"ModifyDate": func(c *ExifCompact) map[string]interface{} {
+		return dateTags(c.ModifyDate, "EXIF:ModifyDate", "EXIF:SubSecTime", "EXIF:OffsetTime")
+	},
+	"GPSLatitude": func(c *ExifCompact) map[string]interface{} {
+		return coordinateTags(c.GPSLatitude, "EXIF:GPSLatitude", "EXIF:GPSLatitudeRef", "N", "S")
+	},
+	"GPSLongitude": func(c *ExifCompact) map[string]interface{} {
+		return coordinateTags(c.GPSLongitude, "EXIF:GPSLongitude", "EXIF:GPSLongitudeRef", "E", "W")
+	},
+	"City": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"XMP-photoshop:City": optString(c.City), "IPTC:City": optString(c.City)}
+	},
+	"State": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"XMP-photoshop:State": optString(c.State), "IPTC:Province-State": optString(c.State)}
+	},
+	"Country": func(c *ExifCompact) map[string]interface{} {
+		return map[string]interface{}{"XMP-photoshop:Country": optString(c.Country), "IPTC:Country-PrimaryLocationName": optString(c.Country)}
+	},
+}
+
+// WriteCompact writes the given fields of c to path, e.g. "Title" or "OriginalDate". Without
+// fields every writable field that differs from what is currently in the file is written.
+// The file is read again afterwards and a *VerifyError is returned if a field did not
+// round-trip. Note that the original is kept as <path>_original, use WriteCompactContext to
+// control that.
+func (tool *MExifTool) WriteCompact(path string, c *ExifCompact, fields ...string) (*WriteResult, error) {
+	ctx, cancel := tool.context()
+	defer cancel()
+	return tool.WriteCompactContext(ctx, path, c, WriteOptions{}, fields...)
+}
+
+func (tool *MExifTool) WriteCompactContext(ctx context.Context, path string, c *ExifCompact, opts WriteOptions, fields ...string) (*WriteResult, error) {
+	return writeCompact(ctx, tool, path, c, opts, fields)
+}
+
+func (pool *MExifToolPool) WriteCompact(path string, c *ExifCompact, fields ...string) (*WriteResult, error) {
+	ctx, cancel := timeoutContext(atomic.LoadInt64(&pool.timeout))
+	defer cancel()
+	return pool.WriteCompactContext(ctx, path, c, WriteOptions{}, fields...)
+}
+
+func (pool *MExifToolPool) WriteCompactContext(ctx context.Context, path string, c *ExifCompact, opts WriteOptions, fields ...string) (*WriteResult, error) {
+	return writeCompact(ctx, pool, path, c, opts, fields)
+}
+
+type compactWriter interface {
+	ExifCompactContext(ctx context.Context, path string) (*ExifCompact, error)
+	WriteTagsContext(ctx context.Context, path string, tags map[string]interface{}, opts WriteOptions) (*WriteResult, error)
+}
+
+func writeCompact(ctx context.Context, w compactWriter, path string, c *ExifCompact, opts WriteOptions, fields []string) (*WriteResult, error) {
+	for _, f := range fields {
+		if _, found := compactTags[f]; !found {
+			return nil, fmt.Errorf("field %s can not be written", f)
+		}
+	}
+	fields = append([]string{}, fields...)
+	if len(fields) == 0 {
+		current, err := w.ExifCompactContext(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		for f := range compactTags {
+			if !compactFieldEqual(f, c, current) {
+				fields = append(fields, f)
+			}
+		}
+		if len(fields) == 0 {
+			return &WriteResult{Unchanged: 1}, nil
+		}
+	}
+	sort.Strings(fields)
+
+	tags := map[string]interface{}{}
+	for _, f := range fields {
+		for tag, value := range compactTags[f](c) {
+			tags[tag] = value
+		}
+	}
+	result, err := w.WriteTagsContext(ctx, path, tags, opts)
+	if err != nil {
+		return result, err
+	}
+
+	written, err := w.ExifCompactContext(ctx, path)
+	if err != nil {
+		return result, fmt.Errorf("could not verify %s: %w", path, err)
+	}
+	var mismatch []string
+	for _, f := range fields {
+		if !compactFieldEqual(f, c, written) {
+			mismatch = append(mismatch, f)
+		}
+	}
+	if len(mismatch) > 0 {
+		return result, &VerifyError{Path: path, Fields: mismatch}
+	}
+	return result, nil
+}
+
+// gpsTolerance is the absolute tolerance in degrees for coordinates. exiftool prints them to
+// 0.01", about 2.8e-6 degrees
+const gpsTolerance = 1e-5
+
+func compactFieldEqual(field string, a, b *ExifCompact) bool {
+	va := reflect.ValueOf(a).Elem().FieldByName(field)
+	vb := reflect.ValueOf(b).Elem().FieldByName(field)
+	switch va.Kind() {
+	case reflect.Float32, reflect.Float64:
+		tolerance := 1e-6 * math.Max(1, math.Abs(va.Float()))
+		if field == "GPSLatitude" || field == "GPSLongitude" {
+			tolerance = gpsTolerance
+		}
+		return math.Abs(va.Float()-vb.Float()) <= tolerance
+	case reflect.Slice:
+		if va.Len() == 0 && vb.Len() == 0 {
+			return true
+		}
+	}
+	if t, ok := va.Interface().(time.Time); ok {
+		return t.Equal(vb.Interface().(time.Time))
+	}
+	return reflect.DeepEqual(va.Interface(), vb.Interface()) || va.IsZero() && vb.IsZero()
+}
+
+func optString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func optNumber(n interface{}) interface{} {
+	if reflect.ValueOf(n).IsZero() {
+		return nil
+	}
+	return n
+}
+
+// dateTags writes the fractional seconds of t to subSecTag, or deletes it so that an old value
+// is not added to the date when it is read
+func dateTags(t time.Time, dateTag string, subSecTag string, offsetTag string) map[string]interface{} {
+	if t.IsZero() {
+		return map[string]interface{}{dateTag: nil, subSecTag: nil, offsetTag: nil}
+	}
+	var subSec interface{}
+	if t.Nanosecond() != 0 {
+		subSec = strings.TrimRight(t.Format(".000000000")[1:], "0")
+	}
+	return map[string]interface{}{
+		dateTag:   t.Format(json.ExifDateTime),
+		subSecTag: subSec,
+		offsetTag: t.Format("-07:00"),
+	}
+}
+
+func coordinateTags(c float64, tag string, refTag string, positive string, negative string) map[string]interface{} {
+	if c == 0 {
+		return map[string]interface{}{tag: nil, refTag: nil}
+	}
+	ref := positive
+	if c < 0 {
+		ref = negative
+	}
+	return map[string]interface{}{tag: math.Abs(c), refTag: ref}
+}